@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+// BatchFunc fetches values of keys at once, e.g. by a multi-get of a remote store.
+// Keys missing from the map returned are reported as ErrKeyNotFound.
+type BatchFunc[K comparable, V any] func(keys []K) (map[K]V, error)
+
+// BatchLoader loads values by keys, collecting concurrent loads within a window or up to a
+// maximum batch size into a single call of the BatchFunc, of which every caller receives the
+// value of its key. Panics of the BatchFunc are returned to callers as ErrProducerPanic.
+// BatchLoader is safe for concurrent use.
+type BatchLoader[K comparable, V any] struct {
+	// Cache caches values loaded if specified, and serves loads of keys cached without fetching.
+	// Errors are not cached.
+	Cache *KeyedCache[K, V]
+
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	batch *batch[K, V]
+}
+
+// batch is a collection of keys to fetch at once.
+type batch[K comparable, V any] struct {
+	keys       []K
+	keyed      map[K]struct{}
+	timer      *time.Timer
+	dispatched bool
+
+	done   chan struct{}
+	values map[K]V
+	err    error
+}
+
+// NewBatchLoader creates a BatchLoader calling fetch with keys loaded within wait since the first
+// of them, or once maxBatch keys are loaded. A maxBatch of zero or less means unbounded.
+func NewBatchLoader[K comparable, V any](fetch BatchFunc[K, V], wait time.Duration, maxBatch int) *BatchLoader[K, V] {
+	return &BatchLoader[K, V]{fetch: fetch, wait: wait, maxBatch: maxBatch}
+}
+
+// Load returns the value of the key, fetched in a batch with concurrent loads.
+func (l *BatchLoader[K, V]) Load(key K) (V, error) {
+	if value, ok := l.cached(key); ok {
+		return value, nil
+	}
+	return l.enqueue(key).result(key)
+}
+
+// LoadAll returns values of the keys, fetched in as few batches as possible. Keys failed to load
+// are left out, and the first error is returned.
+func (l *BatchLoader[K, V]) LoadAll(keys []K) (map[K]V, error) {
+	values := make(map[K]V, len(keys))
+	batches := make(map[K]*batch[K, V], len(keys))
+	for _, key := range keys {
+		if value, ok := l.cached(key); ok {
+			values[key] = value
+		} else {
+			batches[key] = l.enqueue(key)
+		}
+	}
+
+	var err error
+	for key, b := range batches {
+		value, e := b.result(key)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		values[key] = value
+	}
+	return values, err
+}
+
+// Producer returns a Producer loading the value of the key, e.g. for an InlineCache of the key.
+func (l *BatchLoader[K, V]) Producer(key K) Producer[V] {
+	return func(cached V, args ...any) (V, error) {
+		return l.Load(key)
+	}
+}
+
+// KeyedProducer returns a KeyedProducer loading values, e.g. for a TieredCache.
+func (l *BatchLoader[K, V]) KeyedProducer() KeyedProducer[K, V] {
+	return func(key K, cached V, args ...any) (V, error) {
+		return l.Load(key)
+	}
+}
+
+func (l *BatchLoader[K, V]) cached(key K) (value V, ok bool) {
+	if l.Cache == nil {
+		return value, false
+	}
+	return l.Cache.Get(key)
+}
+
+// enqueue adds the key to the current batch, dispatching the batch if full.
+func (l *BatchLoader[K, V]) enqueue(key K) *batch[K, V] {
+	l.mu.Lock()
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{keyed: make(map[K]struct{}), done: make(chan struct{})}
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(b) })
+		l.batch = b
+	}
+	if _, ok := b.keyed[key]; !ok {
+		b.keyed[key] = struct{}{}
+		b.keys = append(b.keys, key)
+	}
+	full := l.maxBatch > 0 && len(b.keys) >= l.maxBatch
+	if full {
+		// Later keys go to the next batch.
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	if full {
+		l.dispatch(b)
+	}
+	return b
+}
+
+// dispatch fetches the batch once, and releases waiters.
+func (l *BatchLoader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if b.dispatched {
+		l.mu.Unlock()
+		return
+	}
+	b.dispatched = true
+	b.timer.Stop()
+	if l.batch == b {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	defer close(b.done)
+	defer func() {
+		if r := recover(); r != nil {
+			b.err = fmt.Errorf("%w: %v", ErrProducerPanic, r)
+		}
+	}()
+	b.values, b.err = l.fetch(b.keys)
+	if b.err == nil && l.Cache != nil {
+		for key, value := range b.values {
+			l.Cache.Set(key, value)
+		}
+	}
+}
+
+// result waits for the batch and returns the value of the key.
+func (b *batch[K, V]) result(key K) (value V, err error) {
+	<-b.done
+	if b.err != nil {
+		return value, b.err
+	}
+	value, ok := b.values[key]
+	if !ok {
+		return value, fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+	}
+	return value, nil
+}
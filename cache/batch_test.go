@@ -0,0 +1,135 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Scusemua/go-utils/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BatchLoader", func() {
+	var mu sync.Mutex
+	var fetched [][]int
+	var fail error
+
+	fetch := func(keys []int) (map[int]string, error) {
+		sorted := append([]int(nil), keys...)
+		sort.Ints(sorted)
+		mu.Lock()
+		fetched = append(fetched, sorted)
+		mu.Unlock()
+		if fail != nil {
+			return nil, fail
+		}
+		values := make(map[int]string, len(keys))
+		for _, key := range keys {
+			if key >= 0 {
+				values[key] = fmt.Sprintf("v%d", key)
+			}
+		}
+		return values, nil
+	}
+
+	batches := func() [][]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]int(nil), fetched...)
+	}
+
+	loadConcurrently := func(l *cache.BatchLoader[int, string], keys ...int) []error {
+		var wg sync.WaitGroup
+		errs := make([]error, len(keys))
+		for i, key := range keys {
+			wg.Add(1)
+			go func(i, key int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				var value string
+				value, errs[i] = l.Load(key)
+				if errs[i] == nil {
+					Expect(value).To(Equal(fmt.Sprintf("v%d", key)))
+				}
+			}(i, key)
+		}
+		wg.Wait()
+		return errs
+	}
+
+	BeforeEach(func() {
+		fetched = nil
+		fail = nil
+	})
+
+	It("should fetch concurrent loads within the window at once", func() {
+		l := cache.NewBatchLoader[int, string](fetch, 50*time.Millisecond, 0)
+		errs := loadConcurrently(l, 1, 2, 3, 2)
+		Expect(errs).To(Equal([]error{nil, nil, nil, nil}))
+		Expect(batches()).To(Equal([][]int{{1, 2, 3}}))
+	})
+
+	It("should dispatch full batches without waiting", func() {
+		l := cache.NewBatchLoader[int, string](fetch, time.Hour, 2)
+		values, err := l.LoadAll([]int{1, 2, 3, 4})
+		Expect(err).To(BeNil())
+		Expect(values).To(Equal(map[int]string{1: "v1", 2: "v2", 3: "v3", 4: "v4"}))
+		Expect(batches()).To(Equal([][]int{{1, 2}, {3, 4}}))
+	})
+
+	It("should report missing keys and errors to each waiter", func() {
+		l := cache.NewBatchLoader[int, string](fetch, time.Millisecond, 2)
+		errs := loadConcurrently(l, 1, -1)
+		Expect(errs[0]).To(BeNil())
+		Expect(errs[1]).To(MatchError(cache.ErrKeyNotFound))
+		Expect(errs[1].Error()).To(Equal("key not found: -1"))
+
+		fail = errors.New("fetch")
+		errs = loadConcurrently(l, 1, 2)
+		Expect(errs).To(Equal([]error{fail, fail}))
+
+		values, err := l.LoadAll([]int{-1})
+		Expect(err).To(Equal(fail))
+		Expect(values).To(BeEmpty())
+	})
+
+	It("should report panics of the batch function", func() {
+		l := cache.NewBatchLoader[int, string](func(keys []int) (map[int]string, error) {
+			panic("fetch")
+		}, time.Millisecond, 0)
+		_, err := l.Load(1)
+		Expect(err).To(MatchError(cache.ErrProducerPanic))
+	})
+
+	It("should serve cached values without fetching", func() {
+		l := cache.NewBatchLoader[int, string](fetch, time.Millisecond, 0)
+		l.Cache = cache.NewKeyedCache[int, string](0, cache.NewLRU[int]())
+		Expect(l.Load(1)).To(Equal("v1"))
+		values, err := l.LoadAll([]int{1, 2})
+		Expect(err).To(BeNil())
+		Expect(values).To(Equal(map[int]string{1: "v1", 2: "v2"}))
+		Expect(batches()).To(Equal([][]int{{1}, {2}}))
+	})
+
+	It("should produce values of caches", func() {
+		l := cache.NewBatchLoader[int, string](fetch, 50*time.Millisecond, 0)
+		a := cache.InlineCache[string]{Concurrent: true, Producer: l.Producer(1)}
+		b := cache.InlineCache[string]{Concurrent: true, Producer: l.Producer(2)}
+
+		var wg sync.WaitGroup
+		for _, c := range []*cache.InlineCache[string]{&a, &b} {
+			wg.Add(1)
+			go func(c *cache.InlineCache[string]) {
+				defer wg.Done()
+				c.Value()
+			}(c)
+		}
+		wg.Wait()
+		Expect(a.Value()).To(Equal("v1"))
+		Expect(b.Value()).To(Equal("v2"))
+		Expect(batches()).To(Equal([][]int{{1, 2}}))
+	})
+})
@@ -0,0 +1,17 @@
+package cache
+
+import "time"
+
+// Clock tells the current time. Inject a fake clock to test expiry without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the Clock of the system time, used if no Clock is specified.
+var SystemClock Clock = systemClock{}
@@ -2,75 +2,375 @@ package cache
 
 import (
 	"errors"
+	"fmt"
+	"math/rand"
 	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Scusemua/go-utils/logger"
 )
 
 var (
 	ErrNotFunction     = errors.New("not function")
 	ErrInvalidFunction = errors.New("invalid function")
+	ErrProducerPanic   = errors.New("producer panicked")
 	DefaultValidKey    = 1
 )
 
 // Inline cache is a general variable cache for costy operation.
 // Example usage:
 // type TypeA struct {
-//   variable InlineCache
+//   variable InlineCache[TypeB]
 // }
 //
 // func NewTypeA() *TypeA {
 // 	typeA := &TypeA{}
-// 	typeA.variable.Producer = NormalizeChainedICProducer(typeA.costOperation)
+// 	typeA.variable.Producer = typeA.costOperation
 // 	return typeA
 // }
 //
-// func (f *Foo) GetVariable(args ...interface{}) TypeB {
+// func (f *Foo) GetVariable(args ...any) TypeB {
 // 	return f.variable.Value(args...)
 // }
 //
-// func (f *Foo) costOperation(cached TypeB, args ...interface{}) (ret TypeB) {
+// func (f *Foo) costOperation(cached TypeB, args ...any) (ret TypeB, err error) {
 // 	// ret = Compute with args...
 // 	return
+// }
 //
+// Producers of other signatures can be adapted by the formalizers on an InlineCache[any],
+// or by AdaptICProducer for a typed cache:
+// 	typeA.variable.Producer = AdaptICProducer[TypeB](FormalizeChainedICProducer(typeA.chainedCostOperation))
+
+// Producer produces the value to cache from the value cached previously, which is the zero value
+// if nothing cached, and arguments.
+type Producer[T any] func(cached T, args ...any) (T, error)
+
+// Validator returns true if the cached value is still valid.
+type Validator[T any] func(cached T) bool
 
 // ICProducer defines formal producer: func(cached TypeA, args...) (value TypeA, error)
 // Alternation:
 // func(cached TypeA, args) (value TypeA)
 // func(args) (value TypeA, error)
 // func(args) (value TypeA)
-type ICProducer func(interface{}, ...interface{}) (interface{}, error)
+type ICProducer = Producer[interface{}]
 
 // ICValidator defines formal validator: func(cached TypeA) (validity bool)
-type ICValidator func(interface{}) bool
+type ICValidator = Validator[interface{}]
+
+// KeyValidator validates the cached value by a validity key, e.g. a version, an ETag or a generation
+// number. It returns the validity and the current key, which the value produced next is cached with.
+type KeyValidator[T any] func(validKey any, cached T) (validity bool, currentKey any)
 
-// TODO: Add building validKey support
-// ICValidator defines formal validator: func(cachedValidKey TypeB, cached TypeA) (validity bool, validKey TypeB)
+// ICKeyValidator defines formal key validator: func(cachedValidKey TypeB, cached TypeA) (validity bool, validKey TypeB)
 // Alternation:
 // func(cached TypeA) (validity bool)
 // func() (validity bool)
-// type ICValidator func(interface{}, interface{}) (bool, interface{})
+type ICKeyValidator = KeyValidator[interface{}]
+
+// InlineCache caches the value of type T produced by the Producer until invalidated or
+// rejected by the Validator. A failed production is retried on the next call, unless the
+// NegativeTTL is set, see ErrorPolicy.
+// Use InlineCache[any] with formalized producers of arbitrary signatures.
+//
+// InlineCache is not safe for concurrent use unless Concurrent is set. StaleWhileRevalidate and
+// RefreshInterval refresh the value in background and imply Concurrent.
+type InlineCache[T any] struct {
+	Producer  Producer[T]
+	Validator Validator[T]
+
+	// KeyValidator validates the cached value by the key it was produced with. It is called before
+	// every production too, with the zero value if nothing cached, to learn the key of the value
+	// to produce. Both validators must accept the value if specified.
+	KeyValidator KeyValidator[T]
+
+	// Concurrent makes the cache safe for concurrent use. Concurrent misses are coalesced into
+	// a single call of the Producer, of which all callers receive the value and error.
+	// The Validator is called with the cache locked.
+	Concurrent bool
+
+	// TTL expires the cached value the duration after produced. Zero means no expiry.
+	TTL time.Duration
+
+	// SlidingTTL expires the cached value the duration after last returned. Zero means no expiry.
+	SlidingTTL time.Duration
+
+	// Jitter extends the TTL by a random duration up to Jitter, so that values produced
+	// together do not expire together.
+	Jitter time.Duration
 
-type InlineCache struct {
-	Producer  ICProducer
-	Validator ICValidator
+	// Clock tells the time of expiry. Defaults to SystemClock.
+	Clock Clock
+
+	// StaleWhileRevalidate returns the value expired or rejected by the Validator immediately,
+	// while refreshing it in background. Callers block only if nothing is cached.
+	StaleWhileRevalidate bool
+
+	// RefreshInterval refreshes the value in background periodically once produced, with the
+	// arguments of the last production. Call Close to stop refreshing.
+	RefreshInterval time.Duration
+
+	// RefreshBackoff delays the next background refresh after a failure, doubled on consecutive
+	// failures up to MaxRefreshBackoff if specified. Zero retries on the next call.
+	RefreshBackoff    time.Duration
+	MaxRefreshBackoff time.Duration
+
+	// ErrorPolicy decides what failed productions return and whether errors are cached.
+	// Defaults to ErrorNotCached.
+	ErrorPolicy ErrorPolicy
+
+	// NegativeTTL caches the failure of a production for the duration, during which the
+	// Producer is not called. Zero retries on the next call. See ErrorPolicy.
+	NegativeTTL time.Duration
+
+	// Logger reports failures of background refreshes and stale values served if specified.
+	Logger logger.Logger
+
+	mu       sync.Mutex
+	cached   T
+	ok       bool
+	expires  time.Time
+	accessed time.Time
+	flight   *flight[T]
+	validKey any
+	nextKey  any
+
+	args     []any
+	failures int
+	retryAt  time.Time
+	stop     chan struct{}
+	closed   bool
+
+	good           bool
+	failure        error
+	failedValue    T
+	failureExpires time.Time
+
+	metrics Metrics
+}
 
-	cached interface{}
-	// validKey interface{}
+// flight is a production in progress.
+type flight[T any] struct {
+	done       chan struct{}
+	value      T
+	err        error
+	validKey   any
+	background bool
 }
 
-func (c *InlineCache) Value(args ...interface{}) interface{} {
+func (c *InlineCache[T]) Value(args ...any) T {
 	cached, _ := c.ValueWithError(args...)
 	return cached
 }
 
-func (c *InlineCache) ValueWithError(args ...interface{}) (cached interface{}, err error) {
-	if c.cached == nil || (c.Validator != nil && !c.Validator(c.cached)) {
-		c.cached, err = c.Producer(c.cached, args...)
+func (c *InlineCache[T]) ValueWithError(args ...any) (T, error) {
+	if c.Concurrent || c.StaleWhileRevalidate || c.RefreshInterval > 0 {
+		return c.valueOnce(args)
 	}
-	return c.cached, err
+
+	if c.valid() {
+		c.metrics.hit()
+		return c.cached, nil
+	} else if value, err, failed := c.cachedFailure(); failed {
+		c.metrics.hit()
+		return value, err
+	}
+
+	c.metrics.miss()
+	start := time.Now()
+	value, err := c.Producer(c.cached, args...)
+	c.metrics.produced(start, err != nil)
+	c.ok = false
+	if err != nil {
+		return c.fail(value, err)
+	}
+	c.store(value, c.nextKey)
+	return value, nil
+}
+
+// Stats returns the statistics of the cache.
+func (c *InlineCache[T]) Stats() Stats {
+	return c.metrics.Stats()
 }
 
-func (c *InlineCache) Invalidate() {
-	c.cached = nil
+// ResetStats zeroes the statistics of the cache.
+func (c *InlineCache[T]) ResetStats() {
+	c.metrics.ResetStats()
+}
+
+// Invalidate drops the cached value. With Concurrent set, a production in progress is
+// invalidated too: its callers still receive the value, but the value is not cached and
+// callers arriving afterward start a new production.
+func (c *InlineCache[T]) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero T
+	c.cached, c.ok, c.good = zero, false, false
+	c.validKey, c.nextKey = nil, nil
+	c.flight = nil
+	c.failure, c.failedValue = nil, zero
+}
+
+// valid returns true if the cached value is neither expired nor rejected, and touches the value if so.
+func (c *InlineCache[T]) valid() bool {
+	if c.KeyValidator != nil {
+		var valid bool
+		valid, c.nextKey = c.KeyValidator(c.validKey, c.cached)
+		if !valid {
+			if c.ok {
+				c.metrics.reject()
+			}
+			return false
+		}
+	}
+	if !c.ok {
+		return false
+	}
+	if c.TTL > 0 || c.SlidingTTL > 0 {
+		now := c.now()
+		if c.TTL > 0 && !now.Before(c.expires) || c.SlidingTTL > 0 && now.Sub(c.accessed) >= c.SlidingTTL {
+			c.metrics.expire()
+			return false
+		}
+		c.accessed = now
+	}
+	if c.Validator != nil && !c.Validator(c.cached) {
+		c.metrics.reject()
+		return false
+	}
+	return true
+}
+
+// store caches the value produced and schedules its expiry.
+func (c *InlineCache[T]) store(value T, validKey any) {
+	c.cached, c.ok, c.good = value, true, true
+	c.validKey = validKey
+	c.failure = nil
+	if c.RefreshInterval > 0 && c.stop == nil && !c.closed {
+		c.stop = make(chan struct{})
+		go c.refreshPeriodically(c.stop)
+	}
+	if c.TTL > 0 || c.SlidingTTL > 0 {
+		now := c.now()
+		c.expires, c.accessed = now.Add(c.TTL), now
+		if c.Jitter > 0 {
+			c.expires = c.expires.Add(time.Duration(rand.Int63n(int64(c.Jitter))))
+		}
+	}
+}
+
+func (c *InlineCache[T]) now() time.Time {
+	if c.Clock == nil {
+		return SystemClock.Now()
+	}
+	return c.Clock.Now()
+}
+
+// valueOnce returns the cached value, or joins the production in progress, or produces.
+func (c *InlineCache[T]) valueOnce(args []any) (T, error) {
+	c.mu.Lock()
+	if c.valid() {
+		c.metrics.hit()
+		cached := c.cached
+		c.mu.Unlock()
+		return cached, nil
+	} else if c.StaleWhileRevalidate && c.ok {
+		c.metrics.hit()
+		c.refreshLocked(args)
+		cached := c.cached
+		c.mu.Unlock()
+		return cached, nil
+	} else if value, err, failed := c.cachedFailure(); failed {
+		c.metrics.hit()
+		c.mu.Unlock()
+		return value, err
+	}
+	c.metrics.miss()
+	if f := c.flight; f != nil {
+		c.mu.Unlock()
+		<-f.done
+		return f.value, f.err
+	}
+
+	f := c.takeoff(args, false)
+	c.mu.Unlock()
+
+	c.produce(f)
+	return f.value, f.err
+}
+
+// takeoff starts a production with the cache locked.
+func (c *InlineCache[T]) takeoff(args []any, background bool) *flight[T] {
+	f := &flight[T]{done: make(chan struct{}), validKey: c.nextKey, background: background}
+	c.flight = f
+	c.args = args
+	return f
+}
+
+// produce calls the Producer for the flight. Panics of background productions are reported as errors.
+func (c *InlineCache[T]) produce(f *flight[T]) {
+	c.mu.Lock()
+	cached, args := c.cached, c.args
+	c.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			f.err = fmt.Errorf("%w: %v", ErrProducerPanic, r)
+			c.metrics.produced(start, true)
+			c.land(f)
+			if !f.background {
+				panic(r)
+			}
+		}
+	}()
+	f.value, f.err = c.Producer(cached, args...)
+	c.metrics.produced(start, f.err != nil)
+	c.land(f)
+}
+
+// land caches the result of the production unless invalidated, and releases waiters.
+func (c *InlineCache[T]) land(f *flight[T]) {
+	c.mu.Lock()
+	if c.flight == f {
+		c.flight = nil
+		if f.err == nil {
+			c.store(f.value, f.validKey)
+			c.failures = 0
+		} else if f.background {
+			c.backoff(f.err)
+		} else {
+			f.value, f.err = c.fail(f.value, f.err)
+		}
+	}
+	c.mu.Unlock()
+	close(f.done)
+}
+
+// AdaptICProducer adapts a formalized producer to the Producer of a typed InlineCache.
+func AdaptICProducer[T any](p ICProducer) Producer[T] {
+	return func(cached T, args ...any) (T, error) {
+		ret, err := p(cached, args...)
+		value, _ := ret.(T)
+		return value, err
+	}
+}
+
+// AdaptICValidator adapts a formalized validator to the Validator of a typed InlineCache.
+func AdaptICValidator[T any](v ICValidator) Validator[T] {
+	return func(cached T) bool {
+		return v(cached)
+	}
+}
+
+// AdaptICKeyValidator adapts a formalized key validator to the KeyValidator of a typed InlineCache.
+func AdaptICKeyValidator[T any](v ICKeyValidator) KeyValidator[T] {
+	return func(validKey any, cached T) (bool, any) {
+		return v(validKey, cached)
+	}
 }
 
 func TryFormalizeICProducer(f interface{}) (ICProducer, error) {
@@ -144,3 +444,43 @@ func FormalizeICValidator(f interface{}) ICValidator {
 		return rets[0].Interface().(bool)
 	}
 }
+
+func TryFormalizeICKeyValidator(f interface{}) (ICKeyValidator, error) {
+	ft := reflect.TypeOf(f)
+	if ft.Kind() != reflect.Func {
+		return nil, ErrNotFunction
+	}
+	switch {
+	case ft.NumIn() == 2 && ft.NumOut() == 2 && ft.Out(0) == reflect.TypeOf(false) && ft.In(0) == ft.Out(1):
+	case ft.NumIn() < 2 && ft.NumOut() == 1 && ft.Out(0) == reflect.TypeOf(false):
+	default:
+		return nil, ErrInvalidFunction
+	}
+	return FormalizeICKeyValidator(f), nil
+}
+
+// FormalizeICKeyValidator formalizes key validators of signatures listed by ICKeyValidator.
+// Validators without keys keep the key unchanged.
+func FormalizeICKeyValidator(f interface{}) ICKeyValidator {
+	fv := reflect.ValueOf(f)
+	zeros := make([]reflect.Value, fv.Type().NumIn())
+	for i := range zeros {
+		zeros[i] = reflect.Zero(fv.Type().In(i))
+	}
+	return func(validKey interface{}, cached interface{}) (bool, interface{}) {
+		args := []interface{}{validKey, cached}[2-len(zeros):]
+		fargs := make([]reflect.Value, len(zeros))
+		for i, arg := range args {
+			if arg == nil {
+				fargs[i] = zeros[i]
+			} else {
+				fargs[i] = reflect.ValueOf(arg)
+			}
+		}
+		rets := fv.Call(fargs)
+		if len(rets) < 2 {
+			return rets[0].Bool(), validKey
+		}
+		return rets[0].Bool(), rets[1].Interface()
+	}
+}
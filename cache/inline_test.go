@@ -1,9 +1,15 @@
 package cache_test
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Scusemua/go-utils/cache"
+	"github.com/Scusemua/go-utils/logger"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -14,7 +20,7 @@ func TestCache(t *testing.T) {
 }
 
 type TypeA struct {
-	variable cache.InlineCache
+	variable cache.InlineCache[any]
 	test     int64
 }
 
@@ -48,6 +54,86 @@ func (f *TypeA) validate(cached float64) bool {
 	return float64(f.test) == cached
 }
 
+type TypeB struct {
+	variable cache.InlineCache[float64]
+	test     int64
+	produced int
+}
+
+func NewTypeB(validate bool) *TypeB {
+	typeB := &TypeB{}
+	typeB.variable.Producer = typeB.costOperation
+	if validate {
+		typeB.variable.Validator = typeB.validate
+	}
+	return typeB
+}
+
+func (f *TypeB) GetVariable(arg int64) float64 {
+	f.test = arg
+	return f.variable.Value(arg)
+}
+
+func (f *TypeB) costOperation(cached float64, args ...any) (float64, error) {
+	f.produced++
+	return float64(args[0].(int64)), nil
+}
+
+func (f *TypeB) validate(cached float64) bool {
+	return float64(f.test) == cached
+}
+
+// recordLogger records warnings and infos.
+type recordLogger struct {
+	logger.Logger
+
+	mu    sync.Mutex
+	warns []string
+	infos []string
+}
+
+func (l *recordLogger) Warn(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warns = append(l.warns, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Info(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.infos = append(l.infos, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Warns() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.warns...)
+}
+
+func (l *recordLogger) Infos() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.infos...)
+}
+
+// fakeClock is a Clock advanced manually.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
 var _ = Describe("InlineCache", func() {
 	It("should example works", func() {
 		a := NewTypeA(false, false)
@@ -62,4 +148,579 @@ var _ = Describe("InlineCache", func() {
 		Expect(c.GetVariable(1)).To(Equal(1.0))
 		Expect(c.GetVariable(2)).To(Equal(2.0))
 	})
+
+	It("should cache typed values", func() {
+		a := NewTypeB(false)
+		Expect(a.GetVariable(1)).To(Equal(1.0))
+		Expect(a.GetVariable(2)).To(Equal(1.0))
+		Expect(a.produced).To(Equal(1))
+
+		a.variable.Invalidate()
+		Expect(a.GetVariable(2)).To(Equal(2.0))
+		Expect(a.produced).To(Equal(2))
+
+		b := NewTypeB(true)
+		Expect(b.GetVariable(1)).To(Equal(1.0))
+		Expect(b.GetVariable(2)).To(Equal(2.0))
+		Expect(b.GetVariable(2)).To(Equal(2.0))
+		Expect(b.produced).To(Equal(2))
+	})
+
+	It("should cache zero values", func() {
+		a := NewTypeB(false)
+		Expect(a.GetVariable(0)).To(Equal(0.0))
+		Expect(a.GetVariable(1)).To(Equal(0.0))
+		Expect(a.produced).To(Equal(1))
+	})
+
+	It("should retry failed production", func() {
+		var c cache.InlineCache[int]
+		errProduce := errors.New("produce")
+		c.Producer = func(cached int, args ...any) (int, error) {
+			if len(args) == 0 {
+				return 0, errProduce
+			}
+			return args[0].(int), nil
+		}
+		_, err := c.ValueWithError()
+		Expect(err).To(Equal(errProduce))
+		Expect(c.ValueWithError(1)).To(Equal(1))
+		Expect(c.Value(2)).To(Equal(1))
+	})
+
+	It("should adapt formalized producers and validators", func() {
+		a := &TypeA{}
+		var c cache.InlineCache[float64]
+		c.Producer = cache.AdaptICProducer[float64](cache.FormalizeChainedICProducer(a.chainedCostOperation))
+		c.Validator = cache.AdaptICValidator[float64](cache.FormalizeICValidator(a.validate))
+
+		a.test = 1
+		Expect(c.Value(int64(1))).To(Equal(1.0))
+		a.test = 2
+		Expect(c.Value(int64(2))).To(Equal(2.0))
+	})
+
+	Context("Concurrent", func() {
+		It("should coalesce concurrent misses into a single production", func() {
+			var produced int32
+			release := make(chan struct{})
+			c := cache.InlineCache[int]{Concurrent: true}
+			c.Producer = func(cached int, args ...any) (int, error) {
+				atomic.AddInt32(&produced, 1)
+				<-release
+				return args[0].(int), nil
+			}
+
+			var wg sync.WaitGroup
+			values := make([]int, 10)
+			for i := range values {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					values[i] = c.Value(i)
+				}(i)
+			}
+			Eventually(func() int32 { return atomic.LoadInt32(&produced) }).Should(Equal(int32(1)))
+			close(release)
+			wg.Wait()
+
+			Expect(produced).To(Equal(int32(1)))
+			for _, value := range values {
+				Expect(value).To(Equal(values[0]))
+			}
+			Expect(c.Value(-1)).To(Equal(values[0]))
+		})
+
+		It("should share errors with waiters and retry", func() {
+			errProduce := errors.New("produce")
+			release := make(chan struct{})
+			started := make(chan struct{})
+			c := cache.InlineCache[int]{Concurrent: true}
+			var once sync.Once
+			c.Producer = func(cached int, args ...any) (int, error) {
+				once.Do(func() { close(started) })
+				<-release
+				return 0, errProduce
+			}
+
+			errs := make(chan error, 2)
+			go func() {
+				_, err := c.ValueWithError()
+				errs <- err
+			}()
+			<-started
+			go func() {
+				_, err := c.ValueWithError()
+				errs <- err
+			}()
+			Consistently(errs, 20*time.Millisecond).ShouldNot(Receive())
+			close(release)
+			Eventually(errs).Should(Receive(Equal(errProduce)))
+			Eventually(errs).Should(Receive(Equal(errProduce)))
+
+			c.Producer = func(cached int, args ...any) (int, error) { return 1, nil }
+			Expect(c.ValueWithError()).To(Equal(1))
+		})
+
+		It("should not cache a production invalidated in progress", func() {
+			release := make(chan struct{})
+			started := make(chan struct{}, 2)
+			c := cache.InlineCache[int]{Concurrent: true}
+			c.Producer = func(cached int, args ...any) (int, error) {
+				started <- struct{}{}
+				<-release
+				return args[0].(int), nil
+			}
+
+			first := make(chan int)
+			go func() { first <- c.Value(1) }()
+			<-started
+			c.Invalidate()
+
+			second := make(chan int)
+			go func() { second <- c.Value(2) }()
+			<-started
+			close(release)
+
+			Eventually(first).Should(Receive(Equal(1)))
+			Eventually(second).Should(Receive(Equal(2)))
+			Expect(c.Value(3)).To(Equal(2))
+		})
+
+		It("should release waiters if the producer panics", func() {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			c := cache.InlineCache[int]{Concurrent: true}
+			var once sync.Once
+			c.Producer = func(cached int, args ...any) (int, error) {
+				once.Do(func() { close(started) })
+				<-release
+				panic("boom")
+			}
+
+			go func() {
+				defer GinkgoRecover()
+				defer func() { Expect(recover()).To(Equal("boom")) }()
+				c.Value()
+			}()
+			<-started
+			waiter := make(chan error)
+			go func() {
+				_, err := c.ValueWithError()
+				waiter <- err
+			}()
+			Consistently(waiter, 20*time.Millisecond).ShouldNot(Receive())
+			close(release)
+			Eventually(waiter).Should(Receive(MatchError(cache.ErrProducerPanic)))
+		})
+	})
+
+	Context("TTL", func() {
+		var clock *fakeClock
+		var produced int
+		var c cache.InlineCache[int]
+
+		BeforeEach(func() {
+			clock = &fakeClock{now: time.Unix(0, 0)}
+			produced = 0
+			c = cache.InlineCache[int]{Clock: clock}
+			c.Producer = func(cached int, args ...any) (int, error) {
+				produced++
+				return produced, nil
+			}
+		})
+
+		It("should expire values after produced", func() {
+			c.TTL = time.Minute
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(59 * time.Second)
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(time.Second)
+			Expect(c.Value()).To(Equal(2))
+		})
+
+		It("should expire values after last returned", func() {
+			c.SlidingTTL = time.Minute
+			Expect(c.Value()).To(Equal(1))
+			for i := 0; i < 3; i++ {
+				clock.Advance(59 * time.Second)
+				Expect(c.Value()).To(Equal(1))
+			}
+			clock.Advance(time.Minute)
+			Expect(c.Value()).To(Equal(2))
+		})
+
+		It("should apply both expiries", func() {
+			c.TTL = 2 * time.Minute
+			c.SlidingTTL = time.Minute
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(59 * time.Second)
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(59 * time.Second)
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(2 * time.Second)
+			Expect(c.Value()).To(Equal(2))
+		})
+
+		It("should extend TTL by jitter", func() {
+			c.TTL = time.Minute
+			c.Jitter = time.Minute
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(59 * time.Second)
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(time.Minute + time.Second)
+			Expect(c.Value()).To(Equal(2))
+		})
+
+		It("should expire values in concurrent mode", func() {
+			c.Concurrent = true
+			c.TTL = time.Minute
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(time.Minute)
+			Expect(c.Value()).To(Equal(2))
+		})
+	})
+
+	Context("Refresh", func() {
+		var clock *fakeClock
+		var produced int32
+		var failing int32
+		var release chan struct{}
+		var c *cache.InlineCache[int]
+		value := func() int { return c.Value() }
+
+		BeforeEach(func() {
+			clock = &fakeClock{now: time.Unix(0, 0)}
+			atomic.StoreInt32(&produced, 0)
+			atomic.StoreInt32(&failing, 0)
+			// Leftover productions of former specs keep blocking on their own channels.
+			wait := make(chan struct{}, 10)
+			release = wait
+			c = &cache.InlineCache[int]{Clock: clock, TTL: time.Minute, StaleWhileRevalidate: true}
+			c.Producer = func(cached int, args ...any) (int, error) {
+				n := atomic.AddInt32(&produced, 1)
+				if n > 1 {
+					<-wait
+				}
+				if atomic.LoadInt32(&failing) > 0 {
+					return cached, errors.New("unavailable")
+				}
+				return int(n), nil
+			}
+		})
+
+		AfterEach(func() {
+			c.Close()
+		})
+
+		It("should return stale values while refreshing in background", func() {
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(time.Minute)
+			Expect(c.Value()).To(Equal(1))
+			Expect(c.Value()).To(Equal(1))
+			Eventually(func() int32 { return atomic.LoadInt32(&produced) }).Should(Equal(int32(2)))
+
+			release <- struct{}{}
+			Eventually(value).Should(Equal(2))
+			Expect(atomic.LoadInt32(&produced)).To(Equal(int32(2)))
+		})
+
+		It("should block if nothing cached", func() {
+			c.Invalidate()
+			Expect(c.Value()).To(Equal(1))
+		})
+
+		It("should back off failed refreshes and report them", func() {
+			log := &recordLogger{}
+			c.Logger = log
+			c.RefreshBackoff = time.Second
+			c.MaxRefreshBackoff = 3 * time.Second
+			Expect(c.Value()).To(Equal(1))
+
+			atomic.StoreInt32(&failing, 1)
+			refresh := func(expected int32) {
+				clock.Advance(time.Minute)
+				release <- struct{}{}
+				Expect(c.Value()).To(Equal(1))
+				Eventually(log.Warns).Should(HaveLen(int(expected) - 1))
+				Expect(atomic.LoadInt32(&produced)).To(Equal(expected))
+			}
+			refresh(2)
+			Expect(log.Warns()[0]).To(Equal("Failed to refresh cached value (1 consecutive failures): unavailable"))
+
+			// Backing off for a second.
+			Expect(c.Value()).To(Equal(1))
+			Consistently(func() int32 { return atomic.LoadInt32(&produced) }, 20*time.Millisecond).Should(Equal(int32(2)))
+			clock.Advance(time.Second)
+			refresh(3)
+
+			// Backing off for two seconds, then capped at three.
+			clock.Advance(time.Second)
+			Expect(c.Value()).To(Equal(1))
+			Consistently(func() int32 { return atomic.LoadInt32(&produced) }, 20*time.Millisecond).Should(Equal(int32(3)))
+			clock.Advance(time.Second)
+			refresh(4)
+
+			atomic.StoreInt32(&failing, 0)
+			clock.Advance(3 * time.Second)
+			release <- struct{}{}
+			Expect(c.Value()).To(Equal(1))
+			Eventually(value).Should(Equal(5))
+		})
+
+		It("should cap the backoff after many failed refreshes", func() {
+			log := &recordLogger{}
+			c.Logger = log
+			c.RefreshBackoff = 10 * time.Second
+			c.MaxRefreshBackoff = 5 * time.Minute
+			Expect(c.Value()).To(Equal(1))
+
+			atomic.StoreInt32(&failing, 1)
+			for failures := 1; failures <= 40; failures++ {
+				clock.Advance(c.MaxRefreshBackoff)
+				release <- struct{}{}
+				Expect(c.Value()).To(Equal(1))
+				Eventually(log.Warns).Should(HaveLen(failures))
+			}
+
+			// Backing off for five minutes.
+			clock.Advance(c.MaxRefreshBackoff - time.Second)
+			Expect(c.Value()).To(Equal(1))
+			Consistently(func() int32 { return atomic.LoadInt32(&produced) }, 20*time.Millisecond).Should(Equal(int32(41)))
+		})
+
+		It("should refresh periodically", func() {
+			c.StaleWhileRevalidate = false
+			c.TTL = 0
+			c.RefreshInterval = 10 * time.Millisecond
+			Expect(c.Value()).To(Equal(1))
+
+			release <- struct{}{}
+			Eventually(value).Should(Equal(2))
+			release <- struct{}{}
+			Eventually(value).Should(Equal(3))
+
+			c.Close()
+			close(release)
+			Consistently(func() int32 { return atomic.LoadInt32(&produced) }, 50*time.Millisecond).Should(BeNumerically("<=", 4))
+		})
+	})
+
+	Context("KeyValidator", func() {
+		var version int
+		var produced int
+		var c cache.InlineCache[string]
+
+		BeforeEach(func() {
+			version, produced = 1, 0
+			c = cache.InlineCache[string]{}
+			c.Producer = func(cached string, args ...any) (string, error) {
+				produced++
+				return fmt.Sprintf("v%d", version), nil
+			}
+		})
+
+		It("should cache values by validity keys", func() {
+			var keys []any
+			c.KeyValidator = func(validKey any, cached string) (bool, any) {
+				keys = append(keys, validKey)
+				return validKey == version, version
+			}
+			Expect(c.Value()).To(Equal("v1"))
+			Expect(c.Value()).To(Equal("v1"))
+			Expect(produced).To(Equal(1))
+
+			version = 2
+			Expect(c.Value()).To(Equal("v2"))
+			Expect(c.Value()).To(Equal("v2"))
+			Expect(produced).To(Equal(2))
+			Expect(keys).To(Equal([]any{nil, 1, 1, 2}))
+		})
+
+		It("should formalize key validators", func() {
+			c.KeyValidator = cache.AdaptICKeyValidator[string](cache.FormalizeICKeyValidator(func(validKey int, cached string) (bool, int) {
+				return validKey == version, version
+			}))
+			Expect(c.Value()).To(Equal("v1"))
+			Expect(c.Value()).To(Equal("v1"))
+			version = 2
+			Expect(c.Value()).To(Equal("v2"))
+			Expect(produced).To(Equal(2))
+		})
+
+		It("should formalize alternative validators", func() {
+			kv, err := cache.TryFormalizeICKeyValidator(func(cached string) bool { return cached == "v1" })
+			Expect(err).To(BeNil())
+			valid, key := kv("key", "v1")
+			Expect(valid).To(BeTrue())
+			Expect(key).To(Equal("key"))
+			valid, key = kv("key", "v2")
+			Expect(valid).To(BeFalse())
+			Expect(key).To(Equal("key"))
+
+			kv, err = cache.TryFormalizeICKeyValidator(func() bool { return true })
+			Expect(err).To(BeNil())
+			valid, _ = kv(nil, nil)
+			Expect(valid).To(BeTrue())
+
+			_, err = cache.TryFormalizeICKeyValidator(func(validKey int, cached string) (bool, string) { return true, "" })
+			Expect(err).To(Equal(cache.ErrInvalidFunction))
+			_, err = cache.TryFormalizeICKeyValidator(1)
+			Expect(err).To(Equal(cache.ErrNotFunction))
+		})
+
+		It("should cache values produced concurrently with the key learned before production", func() {
+			c.Concurrent = true
+			c.KeyValidator = func(validKey any, cached string) (bool, any) {
+				return validKey == version, version
+			}
+			c.Producer = func(cached string, args ...any) (string, error) {
+				value := fmt.Sprintf("v%d", version)
+				version = 2
+				return value, nil
+			}
+			Expect(c.Value()).To(Equal("v1"))
+			Expect(c.Value()).To(Equal("v2"))
+		})
+	})
+
+	Context("ErrorPolicy", func() {
+		var clock *fakeClock
+		var produced []int
+		var fail error
+		var c cache.InlineCache[int]
+
+		BeforeEach(func() {
+			clock = &fakeClock{now: time.Unix(0, 0)}
+			produced = nil
+			fail = nil
+			c = cache.InlineCache[int]{Clock: clock, TTL: time.Minute}
+			c.Producer = func(cached int, args ...any) (int, error) {
+				produced = append(produced, cached)
+				if fail != nil {
+					return -1, fail
+				}
+				return len(produced), nil
+			}
+		})
+
+		It("should not cache failures by default", func() {
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(time.Minute)
+			fail = errors.New("produce")
+			value, err := c.ValueWithError()
+			Expect(value).To(Equal(-1))
+			Expect(err).To(Equal(fail))
+			c.ValueWithError()
+
+			fail = nil
+			Expect(c.Value()).To(Equal(4))
+			Expect(produced).To(Equal([]int{0, 1, 1, 1}))
+		})
+
+		It("should cache failures for the negative TTL", func() {
+			c.ErrorPolicy = cache.ErrorCached
+			c.NegativeTTL = time.Second
+			fail = errors.New("produce")
+			for i := 0; i < 2; i++ {
+				value, err := c.ValueWithError()
+				Expect(value).To(Equal(-1))
+				Expect(err).To(Equal(fail))
+			}
+			Expect(produced).To(HaveLen(1))
+
+			clock.Advance(time.Second)
+			fail = nil
+			Expect(c.Value()).To(Equal(2))
+			c.Invalidate()
+			Expect(c.Stats().Failures).To(Equal(uint64(1)))
+		})
+
+		It("should serve the stale value while failing", func() {
+			log := &recordLogger{}
+			c.Logger = log
+			c.ErrorPolicy = cache.ServeStale
+			c.NegativeTTL = time.Second
+			fail = errors.New("produce")
+			_, err := c.ValueWithError()
+			Expect(err).To(Equal(fail))
+
+			fail = nil
+			clock.Advance(time.Second)
+			Expect(c.Value()).To(Equal(2))
+			clock.Advance(time.Minute)
+			fail = errors.New("produce")
+			for i := 0; i < 2; i++ {
+				value, err := c.ValueWithError()
+				Expect(value).To(Equal(2))
+				Expect(err).To(BeNil())
+			}
+			Expect(produced).To(HaveLen(3))
+			Expect(log.Warns()).To(Equal([]string{"Failed to produce value, serving stale value: produce"}))
+
+			clock.Advance(time.Second)
+			fail = nil
+			Expect(c.Value()).To(Equal(4))
+		})
+
+		It("should serve the stale value to concurrent callers", func() {
+			c.Concurrent = true
+			c.ErrorPolicy = cache.ServeStale
+			Expect(c.Value()).To(Equal(1))
+			clock.Advance(time.Minute)
+			fail = errors.New("produce")
+			value, err := c.ValueWithError()
+			Expect(value).To(Equal(1))
+			Expect(err).To(BeNil())
+			Expect(produced).To(HaveLen(2))
+
+			c.Invalidate()
+			_, err = c.ValueWithError()
+			Expect(err).To(Equal(fail))
+		})
+	})
+
 })
+
+func BenchmarkReflectiveInlineCacheMiss(b *testing.B) {
+	a := NewTypeA(false, false)
+	for i := 0; i < b.N; i++ {
+		a.variable.Invalidate()
+		a.GetVariable(int64(i))
+	}
+}
+
+func BenchmarkTypedInlineCacheMiss(b *testing.B) {
+	a := NewTypeB(false)
+	for i := 0; i < b.N; i++ {
+		a.variable.Invalidate()
+		a.GetVariable(int64(i))
+	}
+}
+
+func BenchmarkReflectiveInlineCacheHit(b *testing.B) {
+	a := NewTypeA(false, true)
+	a.GetVariable(1)
+	for i := 0; i < b.N; i++ {
+		a.GetVariable(1)
+	}
+}
+
+func BenchmarkTypedInlineCacheHit(b *testing.B) {
+	a := NewTypeB(true)
+	a.GetVariable(1)
+	for i := 0; i < b.N; i++ {
+		a.GetVariable(1)
+	}
+}
+
+func BenchmarkConcurrentInlineCacheHit(b *testing.B) {
+	c := cache.InlineCache[float64]{Concurrent: true}
+	c.Producer = func(cached float64, args ...any) (float64, error) { return 1, nil }
+	c.Value()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Value()
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"sync"
+)
+
+// EvictReason tells why an entry left a KeyedCache.
+type EvictReason int
+
+const (
+	// EvictCapacity means the entry was evicted by the Policy to make room.
+	EvictCapacity EvictReason = iota
+	// EvictReplaced means the value was replaced by Set.
+	EvictReplaced
+	// EvictRemoved means the entry was removed by Remove or Clear.
+	EvictRemoved
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictReplaced:
+		return "replaced"
+	case EvictRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// KeyedCache caches values by keys up to the capacity, evicting entries chosen by the Policy.
+// KeyedCache is safe for concurrent use.
+type KeyedCache[K comparable, V any] struct {
+	// OnEvict is called with every entry leaving the cache, e.g. to release resources like the
+	// Closer of a sync.CappedPool. OnEvict is called without the cache locked.
+	OnEvict func(key K, value V, reason EvictReason)
+
+	capacity int
+	policy   Policy[K]
+
+	mu      sync.Mutex
+	entries map[K]V
+	metrics Metrics
+}
+
+// evicted is an entry to call OnEvict with.
+type evicted[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// NewKeyedCache creates a KeyedCache holding up to capacity entries, evicting by the policy,
+// e.g. NewLRU or NewLFU. A capacity of zero or less means unbounded.
+func NewKeyedCache[K comparable, V any](capacity int, policy Policy[K]) *KeyedCache[K, V] {
+	return &KeyedCache[K, V]{capacity: capacity, policy: policy, entries: make(map[K]V)}
+}
+
+// Get returns the value of the key, and false if not cached.
+func (c *KeyedCache[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok = c.entries[key]
+	if ok {
+		c.metrics.hit()
+		c.policy.Access(key)
+	} else {
+		c.metrics.miss()
+	}
+	return value, ok
+}
+
+// Set caches the value of the key, evicting entries if the cache is at capacity.
+func (c *KeyedCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	var evicts []evicted[K, V]
+	if old, ok := c.entries[key]; ok {
+		evicts = append(evicts, evicted[K, V]{key, old, EvictReplaced})
+		c.policy.Access(key)
+	} else {
+		for c.capacity > 0 && len(c.entries) >= c.capacity {
+			victim, ok := c.policy.Victim()
+			if !ok {
+				break
+			}
+			evicts = append(evicts, evicted[K, V]{victim, c.entries[victim], EvictCapacity})
+			c.policy.Remove(victim)
+			delete(c.entries, victim)
+			c.metrics.evict()
+		}
+		c.policy.Add(key)
+	}
+	c.entries[key] = value
+	c.mu.Unlock()
+
+	c.evict(evicts)
+}
+
+// Remove removes the entry of the key, and returns false if not cached.
+func (c *KeyedCache[K, V]) Remove(key K) bool {
+	c.mu.Lock()
+	value, ok := c.entries[key]
+	if ok {
+		c.policy.Remove(key)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		c.evict([]evicted[K, V]{{key, value, EvictRemoved}})
+	}
+	return ok
+}
+
+// Clear removes all entries.
+func (c *KeyedCache[K, V]) Clear() {
+	c.mu.Lock()
+	evicts := make([]evicted[K, V], 0, len(c.entries))
+	for key, value := range c.entries {
+		evicts = append(evicts, evicted[K, V]{key, value, EvictRemoved})
+		c.policy.Remove(key)
+	}
+	c.entries = make(map[K]V)
+	c.mu.Unlock()
+
+	c.evict(evicts)
+}
+
+// Len returns the number of entries cached.
+func (c *KeyedCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Stats returns the statistics of the cache.
+func (c *KeyedCache[K, V]) Stats() Stats {
+	return c.metrics.Stats()
+}
+
+// ResetStats zeroes the statistics of the cache.
+func (c *KeyedCache[K, V]) ResetStats() {
+	c.metrics.ResetStats()
+}
+
+func (c *KeyedCache[K, V]) evict(evicts []evicted[K, V]) {
+	if c.OnEvict == nil {
+		return
+	}
+	for _, e := range evicts {
+		c.OnEvict(e.key, e.value, e.reason)
+	}
+}
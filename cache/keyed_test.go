@@ -0,0 +1,112 @@
+package cache_test
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Scusemua/go-utils/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeyedCache", func() {
+	var evicted []string
+
+	onEvict := func(key string, value int, reason cache.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%s=%d %v", key, value, reason))
+	}
+
+	BeforeEach(func() {
+		evicted = nil
+	})
+
+	It("should evict the least recently used entry", func() {
+		c := cache.NewKeyedCache[string, int](2, cache.NewLRU[string]())
+		c.OnEvict = onEvict
+		c.Set("a", 1)
+		c.Set("b", 2)
+		_, ok := c.Get("a")
+		Expect(ok).To(BeTrue())
+		c.Set("c", 3)
+
+		_, ok = c.Get("b")
+		Expect(ok).To(BeFalse())
+		Expect(c.Len()).To(Equal(2))
+		Expect(evicted).To(Equal([]string{"b=2 capacity"}))
+	})
+
+	It("should evict the least frequently used entry", func() {
+		c := cache.NewKeyedCache[string, int](2, cache.NewLFU[string]())
+		c.OnEvict = onEvict
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Get("a")
+		c.Get("a")
+		c.Get("b")
+		c.Set("c", 3)
+		c.Set("d", 4)
+
+		Expect(evicted).To(Equal([]string{"b=2 capacity", "c=3 capacity"}))
+		value, ok := c.Get("a")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal(1))
+	})
+
+	It("should evict after removal in LFU", func() {
+		c := cache.NewKeyedCache[string, int](2, cache.NewLFU[string]())
+		c.OnEvict = onEvict
+		c.Set("a", 1)
+		c.Get("a")
+		c.Set("b", 2)
+		c.Remove("b")
+		c.Set("c", 3)
+		c.Get("c")
+		c.Get("c")
+		c.Set("d", 4)
+
+		Expect(evicted).To(Equal([]string{"b=2 removed", "a=1 capacity"}))
+	})
+
+	It("should report replaced and removed entries", func() {
+		c := cache.NewKeyedCache[string, int](0, cache.NewLRU[string]())
+		c.OnEvict = onEvict
+		c.Set("a", 1)
+		c.Set("a", 2)
+		Expect(c.Remove("a")).To(BeTrue())
+		Expect(c.Remove("a")).To(BeFalse())
+		c.Set("b", 3)
+		c.Clear()
+
+		Expect(evicted).To(Equal([]string{"a=1 replaced", "a=2 removed", "b=3 removed"}))
+		Expect(c.Len()).To(Equal(0))
+	})
+
+	It("should count hits, misses and evictions", func() {
+		c := cache.NewKeyedCache[int, int](1, cache.NewLRU[int]())
+		c.Set(1, 1)
+		c.Get(1)
+		c.Get(2)
+		c.Set(2, 2)
+
+		stats := c.Stats()
+		Expect(stats).To(Equal(cache.Stats{Hits: 1, Misses: 1, Evictions: 1}))
+		Expect(stats.HitRatio()).To(Equal(0.5))
+	})
+
+	It("should be safe for concurrent use", func() {
+		c := cache.NewKeyedCache[int, int](10, cache.NewLFU[int]())
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					c.Set(i*100+j, j)
+					c.Get(i*100 + j/2)
+				}
+			}(i)
+		}
+		wg.Wait()
+		Expect(c.Len()).To(Equal(10))
+	})
+})
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotComparable = errors.New("arguments not comparable")
+
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// KeyHasher returns the comparable key of the arguments of a memoized function.
+type KeyHasher func(args []interface{}) (interface{}, error)
+
+// MemoizeOptions configures a memoized function.
+type MemoizeOptions struct {
+	// Capacity bounds the number of results cached, evicting the least recently used one.
+	// Zero means unbounded.
+	Capacity int
+
+	// TTL expires results the duration after produced. Zero means no expiry.
+	TTL time.Duration
+
+	// ErrorTTL caches results with non-nil errors for the duration. Zero means errors are not cached.
+	ErrorTTL time.Duration
+
+	// Hasher keys results by arguments. Defaults to DefaultKeyHasher.
+	Hasher KeyHasher
+
+	// Clock tells the time of expiry. Defaults to SystemClock.
+	Clock Clock
+
+	// Metrics records the stats of the memoized function if specified.
+	Metrics *Metrics
+}
+
+// memoized is a result cached with its key and expiry.
+type memoized struct {
+	key     interface{}
+	results []reflect.Value
+	expires time.Time
+}
+
+// Memoize returns a function of the same signature as f, which caches results of f keyed by
+// arguments. Results with non-nil errors, returned last, are not cached.
+// Arguments of non-comparable types, e.g. slices, need a Hasher, see MemoizeWithOptions,
+// otherwise calls are not cached. Memoize panics if f is not a function.
+func Memoize[F any](f F) F {
+	return MemoizeWithOptions(f, MemoizeOptions{})
+}
+
+// MemoizeWithOptions is Memoize configured by options.
+// Concurrent calls of the returned function are safe, while missing calls of the same arguments
+// may call f concurrently.
+func MemoizeWithOptions[F any](f F, opts MemoizeOptions) F {
+	fn, err := TryMemoize(f, opts)
+	if err != nil {
+		panic(err)
+	}
+	return fn
+}
+
+// TryMemoize is MemoizeWithOptions that returns ErrNotFunction instead of panicking.
+func TryMemoize[F any](f F, opts MemoizeOptions) (F, error) {
+	fv := reflect.ValueOf(f)
+	if fv.Kind() != reflect.Func {
+		return f, ErrNotFunction
+	}
+	if opts.Hasher == nil {
+		opts.Hasher = DefaultKeyHasher
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = &Metrics{}
+	}
+
+	ft := fv.Type()
+	withError := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	// Keys of interfaces are not comparable type arguments, so results are cached by ids of keys.
+	var mu sync.Mutex
+	var lastID uint64
+	ids := make(map[interface{}]uint64)
+	cache := NewKeyedCache[uint64, *memoized](opts.Capacity, NewLRU[uint64]())
+	cache.OnEvict = func(id uint64, entry *memoized, reason EvictReason) {
+		if reason == EvictCapacity {
+			opts.Metrics.evict()
+		} else if reason == EvictReplaced {
+			return
+		}
+		mu.Lock()
+		if ids[entry.key] == id {
+			delete(ids, entry.key)
+		}
+		mu.Unlock()
+	}
+	call := fv.Call
+	if ft.IsVariadic() {
+		call = fv.CallSlice
+	}
+
+	memo := reflect.MakeFunc(ft, func(in []reflect.Value) []reflect.Value {
+		key, err := opts.Hasher(memoizeArgs(in, ft.IsVariadic()))
+		if err != nil {
+			return call(in)
+		}
+
+		now := opts.Clock.Now()
+		mu.Lock()
+		id, ok := ids[key]
+		mu.Unlock()
+		if ok {
+			if cached, ok := cache.Get(id); ok {
+				if cached.expires.IsZero() || now.Before(cached.expires) {
+					opts.Metrics.hit()
+					return cached.results
+				}
+				opts.Metrics.expire()
+				cache.Remove(id)
+			}
+		}
+
+		opts.Metrics.miss()
+		start := time.Now()
+		results := call(in)
+		opts.Metrics.produced(start, withError && !results[len(results)-1].IsNil())
+		ttl := opts.TTL
+		if withError && !results[len(results)-1].IsNil() {
+			ttl = opts.ErrorTTL
+			if ttl <= 0 {
+				return results
+			}
+		}
+		entry := &memoized{key: key, results: results}
+		if ttl > 0 {
+			entry.expires = now.Add(ttl)
+		}
+		mu.Lock()
+		id, ok = ids[key]
+		if !ok {
+			lastID++
+			id = lastID
+			ids[key] = id
+		}
+		mu.Unlock()
+		cache.Set(id, entry)
+		return results
+	})
+	return memo.Interface().(F), nil
+}
+
+// memoizeArgs converts arguments to interfaces, expanding variadic arguments.
+func memoizeArgs(in []reflect.Value, variadic bool) []interface{} {
+	args := make([]interface{}, 0, len(in))
+	for i, arg := range in {
+		if variadic && i == len(in)-1 {
+			for j := 0; j < arg.Len(); j++ {
+				args = append(args, arg.Index(j).Interface())
+			}
+			break
+		}
+		args = append(args, arg.Interface())
+	}
+	return args
+}
+
+// DefaultKeyHasher keys comparable arguments by themselves, and returns ErrNotComparable otherwise,
+// including arguments of comparable types holding non-comparable values, e.g. a struct of an
+// interface field holding a slice.
+func DefaultKeyHasher(args []interface{}) (interface{}, error) {
+	key := reflect.New(reflect.ArrayOf(len(args), reflect.TypeOf((*interface{})(nil)).Elem())).Elem()
+	for i, arg := range args {
+		if arg != nil && (!reflect.TypeOf(arg).Comparable() || !hashable(arg)) {
+			return nil, fmt.Errorf("%w: %T", ErrNotComparable, arg)
+		}
+		if arg != nil {
+			key.Index(i).Set(reflect.ValueOf(arg))
+		}
+	}
+	return key.Interface(), nil
+}
+
+// hashable returns true if the value can key a map, by looking it up in a map, which panics if
+// the value holds a non-comparable value.
+func hashable(value interface{}) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = map[interface{}]struct{}{}[value]
+	return true
+}
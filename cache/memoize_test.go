@@ -0,0 +1,133 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Scusemua/go-utils/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memoize", func() {
+	var calls int
+	var errFail = errors.New("fail")
+
+	format := func(n int, s string) (string, error) {
+		calls++
+		if n < 0 {
+			return "", errFail
+		}
+		return fmt.Sprintf("%s%d", s, n), nil
+	}
+
+	BeforeEach(func() {
+		calls = 0
+	})
+
+	It("should cache results by arguments", func() {
+		f := cache.Memoize(format)
+		Expect(f(1, "a")).To(Equal("a1"))
+		Expect(f(1, "a")).To(Equal("a1"))
+		Expect(f(1, "b")).To(Equal("b1"))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("should not cache errors by default", func() {
+		f := cache.Memoize(format)
+		_, err := f(-1, "a")
+		Expect(err).To(Equal(errFail))
+		_, err = f(-1, "a")
+		Expect(err).To(Equal(errFail))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("should cache errors for ErrorTTL", func() {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		f := cache.MemoizeWithOptions(format, cache.MemoizeOptions{TTL: time.Hour, ErrorTTL: time.Second, Clock: clock})
+		f(-1, "a")
+		f(-1, "a")
+		Expect(calls).To(Equal(1))
+
+		clock.Advance(time.Second)
+		f(-1, "a")
+		f(1, "a")
+		Expect(calls).To(Equal(3))
+
+		clock.Advance(time.Minute)
+		f(1, "a")
+		Expect(calls).To(Equal(3))
+		clock.Advance(time.Hour)
+		f(1, "a")
+		Expect(calls).To(Equal(4))
+	})
+
+	It("should bound the number of results", func() {
+		f := cache.MemoizeWithOptions(format, cache.MemoizeOptions{Capacity: 1})
+		f(1, "a")
+		f(2, "a")
+		f(1, "a")
+		Expect(calls).To(Equal(3))
+	})
+
+	It("should key non-comparable arguments by the hasher", func() {
+		sum := func(ns []int) int {
+			calls++
+			total := 0
+			for _, n := range ns {
+				total += n
+			}
+			return total
+		}
+
+		f := cache.Memoize(sum)
+		Expect(f([]int{1, 2})).To(Equal(3))
+		Expect(f([]int{1, 2})).To(Equal(3))
+		Expect(calls).To(Equal(2))
+
+		f = cache.MemoizeWithOptions(sum, cache.MemoizeOptions{Hasher: func(args []interface{}) (interface{}, error) {
+			return fmt.Sprint(args...), nil
+		}})
+		Expect(f([]int{1, 2})).To(Equal(3))
+		Expect(f([]int{1, 2})).To(Equal(3))
+		Expect(calls).To(Equal(3))
+	})
+
+	It("should not cache arguments holding non-comparable values", func() {
+		type wrapper struct{ V interface{} }
+		describe := func(w wrapper) string {
+			calls++
+			return fmt.Sprint(w.V)
+		}
+
+		_, err := cache.DefaultKeyHasher([]interface{}{wrapper{V: []int{1}}})
+		Expect(errors.Is(err, cache.ErrNotComparable)).To(BeTrue())
+
+		f := cache.Memoize(describe)
+		Expect(f(wrapper{V: []int{1}})).To(Equal("[1]"))
+		Expect(f(wrapper{V: []int{1}})).To(Equal("[1]"))
+		Expect(calls).To(Equal(2))
+		Expect(f(wrapper{V: 1})).To(Equal("1"))
+		Expect(f(wrapper{V: 1})).To(Equal("1"))
+		Expect(calls).To(Equal(3))
+	})
+
+	It("should memoize variadic functions", func() {
+		join := func(sep string, parts ...string) string {
+			calls++
+			return fmt.Sprint(len(parts), sep)
+		}
+		f := cache.Memoize(join)
+		Expect(f(",", "a", "b")).To(Equal("2,"))
+		Expect(f(",", "a", "b")).To(Equal("2,"))
+		Expect(f(",", "a")).To(Equal("1,"))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("should fail on non-functions", func() {
+		_, err := cache.TryMemoize(1, cache.MemoizeOptions{})
+		Expect(err).To(Equal(cache.ErrNotFunction))
+		Expect(func() { cache.Memoize("f") }).To(Panic())
+	})
+})
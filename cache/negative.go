@@ -0,0 +1,57 @@
+package cache
+
+// ErrorPolicy decides what a failed production of an InlineCache returns.
+type ErrorPolicy int
+
+const (
+	// ErrorNotCached returns the value and error of the Producer. The value cached previously is
+	// kept, but not returned, and passed to the next production.
+	ErrorNotCached ErrorPolicy = iota
+
+	// ErrorCached is ErrorNotCached that also returns the value and error of the failed
+	// production without calling the Producer until the NegativeTTL expires.
+	ErrorCached
+
+	// ServeStale returns the last value produced successfully, with no error, while the Producer
+	// is failing. The Producer is retried on the next call, or after the NegativeTTL if set.
+	// Without a value produced successfully, the error is returned as ErrorNotCached.
+	ServeStale
+)
+
+func (p ErrorPolicy) String() string {
+	switch p {
+	case ErrorNotCached:
+		return "not cached"
+	case ErrorCached:
+		return "cached"
+	case ServeStale:
+		return "serve stale"
+	default:
+		return "unknown"
+	}
+}
+
+// cachedFailure returns the result of the failed production within the NegativeTTL, and true if so.
+func (c *InlineCache[T]) cachedFailure() (value T, err error, ok bool) {
+	if c.failure == nil || !c.now().Before(c.failureExpires) {
+		return value, nil, false
+	} else if c.ErrorPolicy == ServeStale && c.good {
+		return c.cached, nil, true
+	}
+	return c.failedValue, c.failure, true
+}
+
+// fail records the failed production and returns its result by the ErrorPolicy.
+func (c *InlineCache[T]) fail(value T, err error) (T, error) {
+	if c.ErrorPolicy != ErrorNotCached && c.NegativeTTL > 0 {
+		c.failure, c.failedValue = err, value
+		c.failureExpires = c.now().Add(c.NegativeTTL)
+	}
+	if c.ErrorPolicy == ServeStale && c.good {
+		if c.Logger != nil {
+			c.Logger.Warn("Failed to produce value, serving stale value: %v", err)
+		}
+		return c.cached, nil
+	}
+	return value, err
+}
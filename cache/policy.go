@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"container/list"
+)
+
+// Policy decides which entry to evict from a KeyedCache at capacity.
+// Policies are called with the cache locked and need no synchronization of their own.
+type Policy[K comparable] interface {
+	// Add records a key added to the cache.
+	Add(key K)
+
+	// Access records a hit on the key.
+	Access(key K)
+
+	// Remove forgets the key removed from the cache.
+	Remove(key K)
+
+	// Victim returns the key to evict, or false if no key is recorded.
+	Victim() (K, bool)
+}
+
+// LRU evicts the least recently used entry.
+type LRU[K comparable] struct {
+	order    *list.List
+	elements map[K]*list.Element
+}
+
+func NewLRU[K comparable]() *LRU[K] {
+	return &LRU[K]{order: list.New(), elements: make(map[K]*list.Element)}
+}
+
+func (p *LRU[K]) Add(key K) {
+	if elem, ok := p.elements[key]; ok {
+		p.order.MoveToFront(elem)
+		return
+	}
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *LRU[K]) Access(key K) {
+	if elem, ok := p.elements[key]; ok {
+		p.order.MoveToFront(elem)
+	}
+}
+
+func (p *LRU[K]) Remove(key K) {
+	if elem, ok := p.elements[key]; ok {
+		p.order.Remove(elem)
+		delete(p.elements, key)
+	}
+}
+
+func (p *LRU[K]) Victim() (key K, ok bool) {
+	if back := p.order.Back(); back != nil {
+		return back.Value.(K), true
+	}
+	return key, false
+}
+
+// LFU evicts the least frequently used entry, and the least recently used one among ties.
+type LFU[K comparable] struct {
+	entries map[K]*lfuEntry[K]
+	buckets map[int]*list.List
+	minFreq int
+}
+
+type lfuEntry[K comparable] struct {
+	key  K
+	freq int
+	elem *list.Element
+}
+
+func NewLFU[K comparable]() *LFU[K] {
+	return &LFU[K]{entries: make(map[K]*lfuEntry[K]), buckets: make(map[int]*list.List)}
+}
+
+func (p *LFU[K]) Add(key K) {
+	if _, ok := p.entries[key]; ok {
+		p.Access(key)
+		return
+	}
+	entry := &lfuEntry[K]{key: key, freq: 1}
+	entry.elem = p.bucket(1).PushFront(entry)
+	p.entries[key] = entry
+	p.minFreq = 1
+}
+
+func (p *LFU[K]) Access(key K) {
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	p.unlink(entry)
+	entry.freq++
+	entry.elem = p.bucket(entry.freq).PushFront(entry)
+}
+
+func (p *LFU[K]) Remove(key K) {
+	if entry, ok := p.entries[key]; ok {
+		p.unlink(entry)
+		delete(p.entries, key)
+	}
+}
+
+func (p *LFU[K]) Victim() (key K, ok bool) {
+	if len(p.entries) == 0 {
+		return key, false
+	}
+	if _, ok := p.buckets[p.minFreq]; !ok {
+		// The least frequent bucket was emptied by removal, find the next one.
+		p.minFreq = 0
+		for freq := range p.buckets {
+			if p.minFreq == 0 || freq < p.minFreq {
+				p.minFreq = freq
+			}
+		}
+	}
+	return p.buckets[p.minFreq].Back().Value.(*lfuEntry[K]).key, true
+}
+
+func (p *LFU[K]) bucket(freq int) *list.List {
+	bucket, ok := p.buckets[freq]
+	if !ok {
+		bucket = list.New()
+		p.buckets[freq] = bucket
+	}
+	return bucket
+}
+
+// unlink removes the entry from its bucket and drops the bucket if emptied.
+func (p *LFU[K]) unlink(entry *lfuEntry[K]) {
+	bucket := p.buckets[entry.freq]
+	bucket.Remove(entry.elem)
+	if bucket.Len() == 0 {
+		delete(p.buckets, entry.freq)
+		if p.minFreq == entry.freq {
+			p.minFreq++
+		}
+	}
+}
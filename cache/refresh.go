@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"math"
+	"time"
+)
+
+// Close stops refreshing the value periodically. The cached value is kept.
+func (c *InlineCache[T]) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// refreshLocked starts a background production with the cache locked, unless a production is in
+// progress or backing off.
+func (c *InlineCache[T]) refreshLocked(args []any) {
+	if c.flight != nil || c.now().Before(c.retryAt) {
+		return
+	}
+	go c.produce(c.takeoff(args, true))
+}
+
+func (c *InlineCache[T]) refreshPeriodically(stop chan struct{}) {
+	ticker := time.NewTicker(c.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		c.refreshLocked(c.args)
+		c.mu.Unlock()
+	}
+}
+
+// backoff delays the next background refresh after the failure with the cache locked.
+func (c *InlineCache[T]) backoff(err error) {
+	c.failures++
+	if c.Logger != nil {
+		c.Logger.Warn("Failed to refresh cached value (%d consecutive failures): %v", c.failures, err)
+	}
+	if c.RefreshBackoff <= 0 {
+		return
+	}
+
+	// Stop doubling once beyond MaxRefreshBackoff or before overflow.
+	delay := c.RefreshBackoff
+	for i := 1; i < c.failures && delay <= math.MaxInt64>>1; i++ {
+		if c.MaxRefreshBackoff > 0 && delay >= c.MaxRefreshBackoff {
+			break
+		}
+		delay <<= 1
+	}
+	if c.MaxRefreshBackoff > 0 && delay > c.MaxRefreshBackoff {
+		delay = c.MaxRefreshBackoff
+	}
+	c.retryAt = c.now().Add(delay)
+}
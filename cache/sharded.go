@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/zhangjyr/hashmap"
+)
+
+// ShardKey constrains keys to the types supported by the lock-free hashmap.
+type ShardKey interface {
+	string | int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64 | uintptr
+}
+
+// ShardedCache caches values by keys across shards, each backed by a lock-free hashmap and
+// evicting by its own Policy at its share of the capacity. Lookups take no lock, while writes
+// lock the shard of the key only. ShardedCache is safe for concurrent use.
+type ShardedCache[K ShardKey, V any] struct {
+	// OnEvict is called with every entry leaving the cache, see KeyedCache.OnEvict.
+	OnEvict func(key K, value V, reason EvictReason)
+
+	shards []*shard[K, V]
+	mask   uint64
+}
+
+type shard[K ShardKey, V any] struct {
+	items     *hashmap.HashMap
+	capacity  int
+	newPolicy func() Policy[K]
+
+	// mu guards policy, len, calls and writes to items.
+	mu     sync.Mutex
+	policy Policy[K]
+	len    int
+	calls  map[K]*shardCall[V]
+
+	metrics Metrics
+}
+
+// shardCall is a pending computation of GetOrCompute.
+type shardCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// NewShardedCache creates a ShardedCache of the number of shards holding up to capacity entries,
+// evicting by policies created by newPolicy, e.g. NewLRU[K]. The number of shards is rounded up
+// to a power of two, and defaults to four times GOMAXPROCS if zero or less. The capacity is split
+// across shards, which are reduced to a power of two not exceeding the capacity. A capacity of
+// zero or less means unbounded.
+func NewShardedCache[K ShardKey, V any, P Policy[K]](shards int, capacity int, newPolicy func() P) *ShardedCache[K, V] {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0) * 4
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	// Every shard holds at least an entry, as a shard of zero capacity is unbounded.
+	for capacity > 0 && n > capacity {
+		n >>= 1
+	}
+
+	c := &ShardedCache[K, V]{shards: make([]*shard[K, V], n), mask: uint64(n - 1)}
+	for i := range c.shards {
+		perShard := 0
+		if capacity > 0 {
+			// The remainder goes to the first shards.
+			perShard = capacity / n
+			if i < capacity%n {
+				perShard++
+			}
+		}
+		c.shards[i] = &shard[K, V]{
+			items:     hashmap.New(hashmap.DefaultSize),
+			capacity:  perShard,
+			newPolicy: func() Policy[K] { return newPolicy() },
+			policy:    newPolicy(),
+			calls:     make(map[K]*shardCall[V]),
+		}
+	}
+	return c
+}
+
+// Get returns the value of the key, and false if not cached.
+func (c *ShardedCache[K, V]) Get(key K) (value V, ok bool) {
+	return c.shard(key).get(key)
+}
+
+// Set caches the value of the key, evicting entries if the shard of the key is at capacity.
+func (c *ShardedCache[K, V]) Set(key K, value V) {
+	s := c.shard(key)
+	s.mu.Lock()
+	evicts := s.setLocked(key, value)
+	s.mu.Unlock()
+
+	c.evict(evicts)
+}
+
+// GetOrCompute returns the value of the key, computing and caching it if not cached.
+// Concurrent calls of the same key compute once and share the result. Errors are returned
+// to all waiting callers and not cached. If compute panics, waiting callers get ErrProducerPanic.
+func (c *ShardedCache[K, V]) GetOrCompute(key K, compute func() (V, error)) (V, error) {
+	s := c.shard(key)
+	if value, ok := s.get(key); ok {
+		return value, nil
+	}
+
+	s.mu.Lock()
+	if value, ok := s.load(key); ok {
+		s.mu.Unlock()
+		return value, nil
+	}
+	if call, ok := s.calls[key]; ok {
+		s.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &shardCall[V]{done: make(chan struct{})}
+	s.calls[key] = call
+	s.mu.Unlock()
+
+	var evicts []evicted[K, V]
+	computed := false
+	start := time.Now()
+	defer func() {
+		if !computed {
+			call.err = ErrProducerPanic
+		}
+		s.metrics.produced(start, call.err != nil)
+		s.mu.Lock()
+		if call.err == nil {
+			evicts = s.setLocked(key, call.value)
+		}
+		delete(s.calls, key)
+		s.mu.Unlock()
+		close(call.done)
+
+		c.evict(evicts)
+	}()
+	call.value, call.err = compute()
+	computed = true
+	return call.value, call.err
+}
+
+// Remove removes the entry of the key, and returns false if not cached.
+func (c *ShardedCache[K, V]) Remove(key K) bool {
+	s := c.shard(key)
+	s.mu.Lock()
+	value, ok := s.load(key)
+	if ok {
+		s.items.Del(key)
+		s.policy.Remove(key)
+		s.len--
+	}
+	s.mu.Unlock()
+
+	if ok {
+		c.evict([]evicted[K, V]{{key, value, EvictRemoved}})
+	}
+	return ok
+}
+
+// Clear removes all entries.
+func (c *ShardedCache[K, V]) Clear() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		evicts := make([]evicted[K, V], 0, s.len)
+		for kv := range s.items.Iter() {
+			value, _ := kv.Value.(V)
+			evicts = append(evicts, evicted[K, V]{kv.Key.(K), value, EvictRemoved})
+		}
+		// Lookups read items without locking, so keys are deleted instead of replacing the map.
+		for _, e := range evicts {
+			s.items.Del(e.key)
+		}
+		s.policy = s.newPolicy()
+		s.len = 0
+		s.mu.Unlock()
+
+		c.evict(evicts)
+	}
+}
+
+// Len returns the number of entries cached.
+func (c *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.len
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Stats returns the statistics of the cache summed over shards.
+func (c *ShardedCache[K, V]) Stats() (stats Stats) {
+	for _, s := range c.shards {
+		stats.Add(s.metrics.Stats())
+	}
+	return stats
+}
+
+// ResetStats zeroes the statistics of the cache.
+func (c *ShardedCache[K, V]) ResetStats() {
+	for _, s := range c.shards {
+		s.metrics.ResetStats()
+	}
+}
+
+func (c *ShardedCache[K, V]) shard(key K) *shard[K, V] {
+	return c.shards[shardHash(key)&c.mask]
+}
+
+func (c *ShardedCache[K, V]) evict(evicts []evicted[K, V]) {
+	if c.OnEvict == nil {
+		return
+	}
+	for _, e := range evicts {
+		c.OnEvict(e.key, e.value, e.reason)
+	}
+}
+
+// get looks up the key without locking. The hit is recorded to the policy only if the shard is
+// not locked by others, trading the accuracy of eviction for throughput under contention.
+func (s *shard[K, V]) get(key K) (value V, ok bool) {
+	value, ok = s.load(key)
+	if !ok {
+		s.metrics.miss()
+		return value, false
+	}
+	s.metrics.hit()
+	if s.mu.TryLock() {
+		s.policy.Access(key)
+		s.mu.Unlock()
+	}
+	return value, true
+}
+
+func (s *shard[K, V]) load(key K) (value V, ok bool) {
+	item, ok := s.items.Get(key)
+	if !ok {
+		return value, false
+	}
+	value, _ = item.(V)
+	return value, true
+}
+
+func (s *shard[K, V]) setLocked(key K, value V) (evicts []evicted[K, V]) {
+	if old, ok := s.load(key); ok {
+		evicts = append(evicts, evicted[K, V]{key, old, EvictReplaced})
+		s.policy.Access(key)
+	} else {
+		for s.capacity > 0 && s.len >= s.capacity {
+			victim, ok := s.policy.Victim()
+			if !ok {
+				break
+			}
+			old, _ := s.load(victim)
+			evicts = append(evicts, evicted[K, V]{victim, old, EvictCapacity})
+			s.policy.Remove(victim)
+			s.items.Del(victim)
+			s.len--
+			s.metrics.evict()
+		}
+		s.policy.Add(key)
+		s.len++
+	}
+	s.items.Set(key, value)
+	return evicts
+}
+
+// shardHash spreads keys over shards.
+func shardHash[K ShardKey](key K) uint64 {
+	var h uint64
+	switch k := any(key).(type) {
+	case string:
+		// FNV-1a
+		h = 14695981039346656037
+		for i := 0; i < len(k); i++ {
+			h ^= uint64(k[i])
+			h *= 1099511628211
+		}
+		return h
+	case int:
+		h = uint64(k)
+	case int8:
+		h = uint64(k)
+	case int16:
+		h = uint64(k)
+	case int32:
+		h = uint64(k)
+	case int64:
+		h = uint64(k)
+	case uint:
+		h = uint64(k)
+	case uint8:
+		h = uint64(k)
+	case uint16:
+		h = uint64(k)
+	case uint32:
+		h = uint64(k)
+	case uint64:
+		h = k
+	case uintptr:
+		h = uint64(k)
+	}
+	// Finalizer of splitmix64
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,174 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Scusemua/go-utils/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ShardedCache", func() {
+	It("should get and set values across shards", func() {
+		c := cache.NewShardedCache[string, int](4, 0, cache.NewLRU[string])
+		for i := 0; i < 100; i++ {
+			c.Set(strconv.Itoa(i), i)
+		}
+		for i := 0; i < 100; i++ {
+			value, ok := c.Get(strconv.Itoa(i))
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(i))
+		}
+		_, ok := c.Get("100")
+		Expect(ok).To(BeFalse())
+		Expect(c.Len()).To(Equal(100))
+		Expect(c.Stats()).To(Equal(cache.Stats{Hits: 100, Misses: 1}))
+	})
+
+	It("should evict per shard", func() {
+		var evicted []string
+		c := cache.NewShardedCache[int, int](1, 2, cache.NewLRU[int])
+		c.OnEvict = func(key int, value int, reason cache.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%d=%d %v", key, value, reason))
+		}
+		c.Set(1, 1)
+		c.Set(2, 2)
+		c.Get(1)
+		c.Set(3, 3)
+		c.Set(3, 4)
+		Expect(c.Remove(1)).To(BeTrue())
+
+		Expect(evicted).To(Equal([]string{"2=2 capacity", "3=3 replaced", "1=1 removed"}))
+		Expect(c.Len()).To(Equal(1))
+
+		c.Clear()
+		Expect(c.Len()).To(Equal(0))
+		_, ok := c.Get(3)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should bound the number of entries by the capacity", func() {
+		c := cache.NewShardedCache[int, int](4, 64, cache.NewLFU[int])
+		for i := 0; i < 1000; i++ {
+			c.Set(i, i)
+		}
+		Expect(c.Len()).To(BeNumerically("<=", 64))
+		Expect(c.Stats().Evictions).To(BeNumerically(">=", 1000-64))
+	})
+
+	It("should bound the number of entries by capacities not divisible by shards", func() {
+		for _, shards := range []int{0, 4, 16, 64} {
+			for _, capacity := range []int{1, 3, 10, 17} {
+				c := cache.NewShardedCache[int, int](shards, capacity, cache.NewLRU[int])
+				for i := 0; i < 1000; i++ {
+					c.Set(i, i)
+				}
+				Expect(c.Len()).To(BeNumerically("<=", capacity), "%d shards, capacity %d", shards, capacity)
+				Expect(c.Len()).To(BeNumerically(">", 0))
+			}
+		}
+	})
+
+	It("should compute once for concurrent calls of the same key", func() {
+		c := cache.NewShardedCache[string, int](0, 0, cache.NewLRU[string])
+		var computed int32
+		release := make(chan struct{})
+		compute := func() (int, error) {
+			atomic.AddInt32(&computed, 1)
+			<-release
+			return 1, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				value, err := c.GetOrCompute("key", compute)
+				Expect(err).To(BeNil())
+				Expect(value).To(Equal(1))
+			}()
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&computed) }).Should(Equal(int32(1)))
+		close(release)
+		wg.Wait()
+
+		Expect(atomic.LoadInt32(&computed)).To(Equal(int32(1)))
+		value, ok := c.Get("key")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal(1))
+	})
+
+	It("should not cache errors of computation", func() {
+		c := cache.NewShardedCache[string, int](0, 0, cache.NewLRU[string])
+		errCompute := errors.New("compute")
+		_, err := c.GetOrCompute("key", func() (int, error) { return 0, errCompute })
+		Expect(err).To(Equal(errCompute))
+
+		value, err := c.GetOrCompute("key", func() (int, error) { return 2, nil })
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal(2))
+	})
+
+	It("should recover from panicking computation", func() {
+		c := cache.NewShardedCache[string, int](0, 0, cache.NewLRU[string])
+		Expect(func() {
+			c.GetOrCompute("key", func() (int, error) { panic("compute") })
+		}).To(Panic())
+
+		_, ok := c.Get("key")
+		Expect(ok).To(BeFalse())
+		value, err := c.GetOrCompute("key", func() (int, error) { return 3, nil })
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal(3))
+	})
+})
+
+func benchmarkContention(b *testing.B, get func(key int) int) {
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			get(i & 1023)
+			i++
+		}
+	})
+}
+
+func BenchmarkKeyedCacheContention(b *testing.B) {
+	c := cache.NewKeyedCache[int, int](1024, cache.NewLRU[int]())
+	for i := 0; i < 1024; i++ {
+		c.Set(i, i)
+	}
+	b.ResetTimer()
+	benchmarkContention(b, func(key int) int {
+		value, _ := c.Get(key)
+		return value
+	})
+}
+
+func BenchmarkShardedCacheContention(b *testing.B) {
+	c := cache.NewShardedCache[int, int](0, 1024, cache.NewLRU[int])
+	for i := 0; i < 1024; i++ {
+		c.Set(i, i)
+	}
+	b.ResetTimer()
+	benchmarkContention(b, func(key int) int {
+		value, _ := c.Get(key)
+		return value
+	})
+}
+
+func BenchmarkShardedCacheGetOrCompute(b *testing.B) {
+	c := cache.NewShardedCache[int, int](0, 2048, cache.NewLRU[int])
+	b.ResetTimer()
+	benchmarkContention(b, func(key int) int {
+		value, _ := c.GetOrCompute(key, func() (int, error) { return key, nil })
+		return value
+	})
+}
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Scusemua/go-utils/logger"
+)
+
+// LatencyBuckets is the number of buckets of a Histogram. Bucket i counts latencies up to
+// LatencyBound(i), doubled per bucket from a microsecond, and the last bucket counts the rest.
+const LatencyBuckets = 28
+
+// StatsProvider is a cache reporting its statistics, implemented by all caches of the package.
+type StatsProvider interface {
+	// Stats returns a snapshot of the statistics.
+	Stats() Stats
+
+	// ResetStats zeroes the statistics.
+	ResetStats()
+}
+
+// Stats are statistics of a cache.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+
+	// Rejections counts cached values rejected by validators.
+	Rejections uint64
+
+	// Expirations counts cached values expired by TTLs.
+	Expirations uint64
+
+	// Productions counts calls of producers, of which Failures returned errors or panicked.
+	Productions uint64
+	Failures    uint64
+
+	// Latency is the histogram of latencies of producers.
+	Latency Histogram
+}
+
+// HitRatio returns the ratio of hits to lookups, or 0 if no lookup.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d hits, %d misses (%.2f%% hit), %d evictions, %d rejections, %d expirations, "+
+		"%d productions (%d failed, mean %v, p50 %v, p99 %v, max %v)",
+		s.Hits, s.Misses, s.HitRatio()*100, s.Evictions, s.Rejections, s.Expirations,
+		s.Productions, s.Failures, s.Latency.Mean(), s.Latency.Quantile(0.5), s.Latency.Quantile(0.99), s.Latency.Max)
+}
+
+// Add sums other into the stats.
+func (s *Stats) Add(other Stats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Evictions += other.Evictions
+	s.Rejections += other.Rejections
+	s.Expirations += other.Expirations
+	s.Productions += other.Productions
+	s.Failures += other.Failures
+	s.Latency.Add(other.Latency)
+}
+
+// Histogram counts latencies in exponential buckets, see LatencyBuckets.
+type Histogram struct {
+	Counts [LatencyBuckets]uint64
+	Count  uint64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+// LatencyBound returns the upper bound of bucket i of a Histogram.
+func LatencyBound(i int) time.Duration {
+	if i >= LatencyBuckets-1 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Microsecond << i
+}
+
+// Mean returns the mean latency, or 0 if nothing counted.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket of the latency at quantile q in [0, 1],
+// capped by the maximum latency, or 0 if nothing counted.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.Count))
+	if rank >= h.Count {
+		rank = h.Count - 1
+	}
+	var seen uint64
+	for i, count := range h.Counts {
+		seen += count
+		if seen > rank {
+			if bound := LatencyBound(i); bound < h.Max {
+				return bound
+			}
+			break
+		}
+	}
+	return h.Max
+}
+
+// Add sums other into the histogram.
+func (h *Histogram) Add(other Histogram) {
+	for i, count := range other.Counts {
+		h.Counts[i] += count
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+}
+
+// Metrics records Stats of a cache. Metrics is safe for concurrent use without locking, and is a
+// StatsProvider of itself, e.g. to collect stats of Memoize by MemoizeOptions.Metrics.
+// Snapshots are not atomic across counters.
+type Metrics struct {
+	// counters holds *metricCounters allocated on first use. Counters are updated by 64-bit
+	// atomic operations, which need 64-bit alignment on 32-bit platforms, guaranteed only for
+	// allocated words, while Metrics is embedded at any offset of caches.
+	counters atomic.Value
+}
+
+type metricCounters struct {
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	rejections  uint64
+	expirations uint64
+	productions uint64
+	failures    uint64
+
+	latencies [LatencyBuckets]uint64
+	sum       int64
+	max       int64
+}
+
+// Stats returns a snapshot of the stats recorded.
+func (m *Metrics) Stats() (stats Stats) {
+	c := m.c()
+	stats.Hits = atomic.LoadUint64(&c.hits)
+	stats.Misses = atomic.LoadUint64(&c.misses)
+	stats.Evictions = atomic.LoadUint64(&c.evictions)
+	stats.Rejections = atomic.LoadUint64(&c.rejections)
+	stats.Expirations = atomic.LoadUint64(&c.expirations)
+	stats.Productions = atomic.LoadUint64(&c.productions)
+	stats.Failures = atomic.LoadUint64(&c.failures)
+	for i := range c.latencies {
+		stats.Latency.Counts[i] = atomic.LoadUint64(&c.latencies[i])
+		stats.Latency.Count += stats.Latency.Counts[i]
+	}
+	stats.Latency.Sum = time.Duration(atomic.LoadInt64(&c.sum))
+	stats.Latency.Max = time.Duration(atomic.LoadInt64(&c.max))
+	return stats
+}
+
+// ResetStats zeroes the stats recorded.
+func (m *Metrics) ResetStats() {
+	m.counters.Store(&metricCounters{})
+}
+
+func (m *Metrics) c() *metricCounters {
+	if c, ok := m.counters.Load().(*metricCounters); ok {
+		return c
+	}
+	m.counters.CompareAndSwap(nil, &metricCounters{})
+	return m.counters.Load().(*metricCounters)
+}
+
+func (m *Metrics) hit() {
+	atomic.AddUint64(&m.c().hits, 1)
+}
+
+func (m *Metrics) miss() {
+	atomic.AddUint64(&m.c().misses, 1)
+}
+
+func (m *Metrics) evict() {
+	atomic.AddUint64(&m.c().evictions, 1)
+}
+
+func (m *Metrics) reject() {
+	atomic.AddUint64(&m.c().rejections, 1)
+}
+
+func (m *Metrics) expire() {
+	atomic.AddUint64(&m.c().expirations, 1)
+}
+
+// produced records a production started at the time.
+func (m *Metrics) produced(start time.Time, failed bool) {
+	c := m.c()
+	atomic.AddUint64(&c.productions, 1)
+	if failed {
+		atomic.AddUint64(&c.failures, 1)
+	}
+
+	latency := time.Since(start)
+	i := 0
+	for i < LatencyBuckets-1 && latency > LatencyBound(i) {
+		i++
+	}
+	atomic.AddUint64(&c.latencies[i], 1)
+	atomic.AddInt64(&c.sum, int64(latency))
+	for {
+		max := atomic.LoadInt64(&c.max)
+		if int64(latency) <= max || atomic.CompareAndSwapInt64(&c.max, max, int64(latency)) {
+			return
+		}
+	}
+}
+
+// LogStats logs the stats of the named cache through the logger every interval, resetting the
+// stats after logging if reset is set, until the returned function is called.
+func LogStats(log logger.Logger, name string, provider StatsProvider, interval time.Duration, reset bool) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			log.Info("Cache %s: %v", name, provider.Stats())
+			if reset {
+				provider.ResetStats()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,126 @@
+package cache_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Scusemua/go-utils/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stats", func() {
+	It("should summarize latencies", func() {
+		var h cache.Histogram
+		Expect(h.Mean()).To(Equal(time.Duration(0)))
+		Expect(h.Quantile(0.5)).To(Equal(time.Duration(0)))
+
+		h.Counts[0], h.Counts[3], h.Counts[10] = 50, 49, 1
+		h.Count, h.Sum, h.Max = 100, 100*time.Millisecond, 1000*time.Microsecond
+		Expect(h.Mean()).To(Equal(time.Millisecond))
+		Expect(h.Quantile(0.3)).To(Equal(time.Microsecond))
+		Expect(h.Quantile(0.9)).To(Equal(8 * time.Microsecond))
+		Expect(h.Quantile(1)).To(Equal(1000 * time.Microsecond))
+		Expect(cache.LatencyBound(cache.LatencyBuckets - 2)).To(Equal(time.Microsecond << 26))
+	})
+
+	It("should count lookups and productions of InlineCache", func() {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		valid := true
+		fail := false
+		c := cache.InlineCache[int]{Clock: clock, TTL: time.Minute}
+		c.Validator = func(cached int) bool { return valid }
+		c.Producer = func(cached int, args ...any) (int, error) {
+			if fail {
+				return 0, errors.New("fail")
+			}
+			return cached + 1, nil
+		}
+
+		c.Value()
+		c.Value()
+		valid = false
+		c.Value()
+		valid = true
+		clock.Advance(time.Minute)
+		fail = true
+		c.Value()
+
+		stats := c.Stats()
+		Expect(stats.Hits).To(Equal(uint64(1)))
+		Expect(stats.Misses).To(Equal(uint64(3)))
+		Expect(stats.Rejections).To(Equal(uint64(1)))
+		Expect(stats.Expirations).To(Equal(uint64(1)))
+		Expect(stats.Productions).To(Equal(uint64(3)))
+		Expect(stats.Failures).To(Equal(uint64(1)))
+		Expect(stats.Latency.Count).To(Equal(uint64(3)))
+
+		c.ResetStats()
+		Expect(c.Stats()).To(Equal(cache.Stats{}))
+	})
+
+	It("should count productions of concurrent caches", func() {
+		var inline cache.InlineCache[int]
+		inline.Concurrent = true
+		inline.Producer = func(cached int, args ...any) (int, error) { return 1, nil }
+		inline.Value()
+		inline.Value()
+
+		sharded := cache.NewShardedCache[int, int](2, 0, cache.NewLRU[int])
+		sharded.GetOrCompute(1, func() (int, error) { return 1, nil })
+		sharded.GetOrCompute(1, func() (int, error) { return 1, nil })
+
+		for _, provider := range []cache.StatsProvider{&inline, sharded} {
+			stats := provider.Stats()
+			Expect(stats.Hits).To(Equal(uint64(1)))
+			Expect(stats.Productions).To(Equal(uint64(1)))
+			Expect(stats.Latency.Count).To(Equal(uint64(1)))
+		}
+	})
+
+	It("should record stats of caches embedded at any offset", func() {
+		// On 32-bit platforms, the cache is not 64-bit aligned after a 32-bit field.
+		var holder struct {
+			flag  int32
+			cache cache.InlineCache[int]
+		}
+		holder.cache.Producer = func(cached int, args ...any) (int, error) { return 1, nil }
+		Expect(holder.cache.Value()).To(Equal(1))
+		Expect(holder.cache.Value()).To(Equal(1))
+		Expect(holder.cache.Stats().Hits).To(Equal(uint64(1)))
+
+		holder.cache.ResetStats()
+		Expect(holder.cache.Stats()).To(Equal(cache.Stats{}))
+	})
+
+	It("should record stats of memoized functions", func() {
+		var metrics cache.Metrics
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		f := cache.MemoizeWithOptions(func(n int) int { return n }, cache.MemoizeOptions{
+			Capacity: 1, TTL: time.Minute, Clock: clock, Metrics: &metrics})
+		f(1)
+		f(1)
+		f(2)
+		clock.Advance(time.Minute)
+		f(2)
+
+		stats := metrics.Stats()
+		Expect([]uint64{stats.Hits, stats.Misses, stats.Evictions, stats.Expirations, stats.Productions}).
+			To(Equal([]uint64{1, 3, 1, 1, 3}))
+	})
+
+	It("should log stats periodically", func() {
+		log := &recordLogger{}
+		c := cache.NewKeyedCache[int, int](0, cache.NewLRU[int]())
+		c.Get(1)
+		stop := cache.LogStats(log, "test", c, 10*time.Millisecond, true)
+		defer stop()
+
+		Eventually(func() int { return len(log.Infos()) }).Should(BeNumerically(">=", 2))
+		stop()
+		stop()
+		infos := log.Infos()
+		Expect(infos[0]).To(HavePrefix("Cache test: 0 hits, 1 misses (0.00% hit), 0 evictions"))
+		Expect(infos[1]).To(HavePrefix("Cache test: 0 hits, 0 misses"))
+	})
+})
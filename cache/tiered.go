@@ -0,0 +1,478 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Scusemua/go-utils/logger"
+)
+
+const (
+	tieredExt        = ".cache"
+	tieredTempPrefix = ".tmp-"
+)
+
+// KeyedProducer produces the value of the key to cache, see Producer.
+type KeyedProducer[K comparable, V any] func(key K, cached V, args ...any) (V, error)
+
+// TieredCache caches values produced by the Producer in memory, backed by a directory of files
+// that survive restarts. A miss in memory loads the value from disk, and a miss on disk produces
+// the value and writes it to both tiers. Like a Concurrent InlineCache, concurrent misses of a key
+// are coalesced into a single call of the Producer, and a failed production is retried on the
+// next call. TieredCache is safe for concurrent use, but not by multiple processes.
+//
+// Each value is written to a file of its own by the Codec, through a temporary file renamed into
+// place, so that a crash leaves either the old or the new value. Files are written without
+// blocking lookups of other keys.
+type TieredCache[K comparable, V any] struct {
+	Producer  KeyedProducer[K, V]
+	Validator Validator[V]
+
+	// TTL expires values the duration after produced, in both tiers. Zero means no expiry.
+	TTL time.Duration
+
+	// Codec encodes keys and values on disk. Defaults to GobCodec.
+	Codec Codec
+
+	// MaxDiskBytes bounds the size of files on disk, removing the least recently used ones
+	// when exceeded. Zero means unbounded.
+	MaxDiskBytes int64
+
+	// Clock tells the time of expiry. Defaults to SystemClock.
+	Clock Clock
+
+	// Logger reports failures of the disk tier if specified. Such failures are not returned,
+	// as values are still served from memory or produced.
+	Logger logger.Logger
+
+	dir    string
+	memory *KeyedCache[K, *tieredEntry[V]]
+
+	// mu guards flights and the index of files, and is never held during disk I/O.
+	mu        sync.Mutex
+	flights   map[K]*flight[V]
+	files     map[string]*diskFile
+	diskBytes int64
+
+	// diskMu serializes renames and removals of files, ordering them with the index.
+	diskMu sync.Mutex
+
+	metrics Metrics
+}
+
+type tieredEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+type diskFile struct {
+	size     int64
+	accessed time.Time
+}
+
+// tieredRecord is the content of a file on disk.
+type tieredRecord[K comparable, V any] struct {
+	Key     K
+	Expires time.Time
+	Value   V
+}
+
+// NewTieredCache creates a TieredCache holding up to capacity values in memory, and values on
+// disk in the directory, which is created if not exists. Values left in the directory by a
+// previous TieredCache are loaded on demand. A capacity of zero or less means unbounded.
+func NewTieredCache[K comparable, V any](dir string, capacity int) (*TieredCache[K, V], error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &TieredCache[K, V]{
+		dir:     dir,
+		memory:  NewKeyedCache[K, *tieredEntry[V]](capacity, NewLRU[K]()),
+		flights: make(map[K]*flight[V]),
+		files:   make(map[string]*diskFile),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, tieredTempPrefix) {
+			// Left by an interrupted write.
+			os.Remove(filepath.Join(dir, name))
+			continue
+		} else if entry.IsDir() || filepath.Ext(name) != tieredExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		c.files[name] = &diskFile{size: info.Size(), accessed: info.ModTime()}
+		c.diskBytes += info.Size()
+	}
+	return c, nil
+}
+
+func (c *TieredCache[K, V]) Value(key K, args ...any) V {
+	value, _ := c.ValueWithError(key, args...)
+	return value
+}
+
+func (c *TieredCache[K, V]) ValueWithError(key K, args ...any) (V, error) {
+	c.mu.Lock()
+	entry, cached := c.memory.Get(key)
+	if cached && c.valid(entry) {
+		c.mu.Unlock()
+		c.metrics.hit()
+		return entry.value, nil
+	} else if f, ok := c.flights[key]; ok {
+		c.metrics.miss()
+		c.mu.Unlock()
+		<-f.done
+		return f.value, f.err
+	}
+	f := &flight[V]{done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	var stale V
+	if cached {
+		stale = entry.value
+	}
+	c.produce(key, f, stale, cached, args)
+	return f.value, f.err
+}
+
+// Invalidate drops the value of the key from both tiers. A production of the key in progress is
+// invalidated too, see InlineCache.Invalidate.
+func (c *TieredCache[K, V]) Invalidate(key K) error {
+	c.diskMu.Lock()
+	defer c.diskMu.Unlock()
+
+	name := fileName(key)
+	c.mu.Lock()
+	c.memory.Remove(key)
+	delete(c.flights, key)
+	c.unindexLocked(name)
+	c.mu.Unlock()
+	return c.removeFiles([]string{name})
+}
+
+// Compact removes files of expired or undecodable values, and the least recently used files
+// beyond MaxDiskBytes.
+func (c *TieredCache[K, V]) Compact() error {
+	c.diskMu.Lock()
+	defer c.diskMu.Unlock()
+
+	c.mu.Lock()
+	names := make([]string, 0, len(c.files))
+	for name := range c.files {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+
+	now := c.now()
+	var removes []string
+	for _, name := range names {
+		var record tieredRecord[K, V]
+		data, err := os.ReadFile(filepath.Join(c.dir, name))
+		if err == nil {
+			err = c.codec().Unmarshal(data, &record)
+		}
+		if err != nil || !record.Expires.IsZero() && !now.Before(record.Expires) {
+			removes = append(removes, name)
+		}
+	}
+
+	c.mu.Lock()
+	for _, name := range removes {
+		c.unindexLocked(name)
+	}
+	removes = append(removes, c.trimLocked()...)
+	c.mu.Unlock()
+	return c.removeFiles(removes)
+}
+
+// Stats returns the statistics of the cache. Values loaded from disk are counted as hits, and
+// evictions from either tier are counted.
+func (c *TieredCache[K, V]) Stats() Stats {
+	stats := c.metrics.Stats()
+	stats.Evictions += c.memory.Stats().Evictions
+	return stats
+}
+
+// ResetStats zeroes the statistics of the cache.
+func (c *TieredCache[K, V]) ResetStats() {
+	c.metrics.ResetStats()
+	c.memory.ResetStats()
+}
+
+// DiskBytes returns the size of files on disk.
+func (c *TieredCache[K, V]) DiskBytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.diskBytes
+}
+
+// valid returns true if the entry is neither expired nor rejected, with the cache locked.
+func (c *TieredCache[K, V]) valid(entry *tieredEntry[V]) bool {
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.metrics.expire()
+		return false
+	}
+	if c.Validator != nil && !c.Validator(entry.value) {
+		c.metrics.reject()
+		return false
+	}
+	return true
+}
+
+// produce loads the value of the flight from disk, or calls the Producer with the value cached
+// previously in either tier.
+func (c *TieredCache[K, V]) produce(key K, f *flight[V], cached V, ok bool, args []any) {
+	if record, loaded := c.load(key); loaded {
+		entry := &tieredEntry[V]{value: record.Value, expires: record.Expires}
+		c.mu.Lock()
+		valid := c.valid(entry)
+		c.mu.Unlock()
+		if valid {
+			c.metrics.hit()
+			f.value = entry.value
+			c.land(key, f, entry, false)
+			return
+		} else if !ok {
+			cached = record.Value
+		}
+	}
+
+	c.metrics.miss()
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			f.err = fmt.Errorf("%w: %v", ErrProducerPanic, r)
+			c.metrics.produced(start, true)
+			c.land(key, f, nil, false)
+			panic(r)
+		}
+	}()
+	f.value, f.err = c.Producer(key, cached, args...)
+	c.metrics.produced(start, f.err != nil)
+	if f.err != nil {
+		c.land(key, f, nil, false)
+		return
+	}
+	entry := &tieredEntry[V]{value: f.value}
+	if c.TTL > 0 {
+		entry.expires = c.now().Add(c.TTL)
+	}
+	c.land(key, f, entry, true)
+}
+
+// land caches the entry unless invalidated, writing it to disk if persist is set, and releases waiters.
+// Waiters are released before the file is renamed into place.
+func (c *TieredCache[K, V]) land(key K, f *flight[V], entry *tieredEntry[V], persist bool) {
+	var tmp string
+	var size int64
+	if entry != nil && persist {
+		var err error
+		if tmp, size, err = c.writeTemp(key, entry); err != nil {
+			c.warn("Failed to write cached value of %v to disk: %v", key, err)
+		}
+	}
+
+	if tmp != "" {
+		// Hold diskMu till renamed, so that an invalidation of the key either precedes the check
+		// of the flight or removes the file renamed.
+		c.diskMu.Lock()
+		defer c.diskMu.Unlock()
+	}
+
+	c.mu.Lock()
+	current := c.flights[key] == f
+	if current {
+		delete(c.flights, key)
+		if entry != nil {
+			c.memory.Set(key, entry)
+		}
+	}
+	c.mu.Unlock()
+	close(f.done)
+
+	if tmp == "" {
+		return
+	} else if !current {
+		os.Remove(tmp)
+		return
+	}
+	if err := c.persist(key, tmp, size); err != nil {
+		c.warn("Failed to write cached value of %v to disk: %v", key, err)
+	}
+}
+
+// load reads the record of the key from disk. Undecodable files are removed.
+func (c *TieredCache[K, V]) load(key K) (record tieredRecord[K, V], ok bool) {
+	name := fileName(key)
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.warn("Failed to read cached value of %v from disk: %v", key, err)
+		}
+		return record, false
+	}
+
+	if err := c.codec().Unmarshal(data, &record); err != nil {
+		c.warn("Failed to decode cached value of %v, removing: %v", key, err)
+		c.diskMu.Lock()
+		defer c.diskMu.Unlock()
+		c.mu.Lock()
+		c.unindexLocked(name)
+		c.mu.Unlock()
+		c.removeFiles([]string{name})
+		return record, false
+	} else if record.Key != key {
+		// Collision of file names, to be overwritten.
+		return record, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if file, ok := c.files[name]; ok {
+		file.accessed = c.now()
+	}
+	return record, true
+}
+
+// writeTemp writes the entry to a temporary file synced to disk, and returns its path and size.
+func (c *TieredCache[K, V]) writeTemp(key K, entry *tieredEntry[V]) (string, int64, error) {
+	data, err := c.codec().Marshal(&tieredRecord[K, V]{Key: key, Expires: entry.expires, Value: entry.value})
+	if err != nil {
+		return "", 0, err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, tieredTempPrefix+"*")
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	return tmp.Name(), int64(len(data)), nil
+}
+
+// persist renames the temporary file of the key into place with diskMu locked, and trims the disk tier.
+func (c *TieredCache[K, V]) persist(key K, tmp string, size int64) error {
+	name := fileName(key)
+	if err := os.Rename(tmp, filepath.Join(c.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	c.mu.Lock()
+	c.unindexLocked(name)
+	c.files[name] = &diskFile{size: size, accessed: c.now()}
+	c.diskBytes += size
+	removes := c.trimLocked()
+	c.mu.Unlock()
+
+	// Sync the directory for the rename to survive a crash.
+	err := c.syncDir()
+	if e := c.removeFiles(removes); err == nil {
+		err = e
+	}
+	return err
+}
+
+// trimLocked drops the least recently used files from the index until within MaxDiskBytes, and
+// returns their names to remove.
+func (c *TieredCache[K, V]) trimLocked() (removes []string) {
+	if c.MaxDiskBytes <= 0 || c.diskBytes <= c.MaxDiskBytes {
+		return nil
+	}
+
+	names := make([]string, 0, len(c.files))
+	for name := range c.files {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c.files[names[i]].accessed.Before(c.files[names[j]].accessed)
+	})
+	for _, name := range names {
+		if c.diskBytes <= c.MaxDiskBytes {
+			break
+		}
+		c.unindexLocked(name)
+		removes = append(removes, name)
+		c.metrics.evict()
+	}
+	return removes
+}
+
+func (c *TieredCache[K, V]) unindexLocked(name string) {
+	if file, ok := c.files[name]; ok {
+		c.diskBytes -= file.size
+		delete(c.files, name)
+	}
+}
+
+// removeFiles removes the files with diskMu locked, and returns the first error.
+func (c *TieredCache[K, V]) removeFiles(names []string) (err error) {
+	for _, name := range names {
+		if e := os.Remove(filepath.Join(c.dir, name)); e != nil && !errors.Is(e, fs.ErrNotExist) && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (c *TieredCache[K, V]) syncDir() error {
+	dir, err := os.Open(c.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (c *TieredCache[K, V]) codec() Codec {
+	if c.Codec == nil {
+		return GobCodec
+	}
+	return c.Codec
+}
+
+func (c *TieredCache[K, V]) now() time.Time {
+	if c.Clock == nil {
+		return SystemClock.Now()
+	}
+	return c.Clock.Now()
+}
+
+func (c *TieredCache[K, V]) warn(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Warn(format, args...)
+	}
+}
+
+// fileName returns the name of the file of the key on disk.
+func fileName(key any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v", key)))
+	return hex.EncodeToString(sum[:16]) + tieredExt
+}
@@ -0,0 +1,223 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Scusemua/go-utils/cache"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// blockingCodec blocks encoding values containing "slow" until released.
+type blockingCodec struct {
+	cache.Codec
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCodec) Marshal(v interface{}) ([]byte, error) {
+	if strings.Contains(fmt.Sprint(v), "slow") {
+		close(c.started)
+		<-c.release
+	}
+	return c.Codec.Marshal(v)
+}
+
+var _ = Describe("TieredCache", func() {
+	var dir string
+	var clock *fakeClock
+	var produced int32
+
+	produce := func(key string, cached string, args ...any) (string, error) {
+		n := atomic.AddInt32(&produced, 1)
+		return fmt.Sprintf("%s%d<%s>", key, n, cached), nil
+	}
+
+	open := func(capacity int) *cache.TieredCache[string, string] {
+		c, err := cache.NewTieredCache[string, string](dir, capacity)
+		Expect(err).To(BeNil())
+		c.Producer = produce
+		c.Clock = clock
+		return c
+	}
+
+	files := func() []string {
+		entries, err := os.ReadDir(dir)
+		Expect(err).To(BeNil())
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "tiered")
+		Expect(err).To(BeNil())
+		clock = &fakeClock{now: time.Unix(0, 0)}
+		atomic.StoreInt32(&produced, 0)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should survive restarts", func() {
+		c := open(1)
+		Expect(c.Value("a")).To(Equal("a1<>"))
+		Expect(c.Value("b")).To(Equal("b2<>"))
+		Expect(c.Value("a")).To(Equal("a1<>"))
+		Expect(atomic.LoadInt32(&produced)).To(Equal(int32(2)))
+		Expect(files()).To(HaveLen(2))
+
+		c = open(1)
+		Expect(c.Value("a")).To(Equal("a1<>"))
+		Expect(c.Value("b")).To(Equal("b2<>"))
+		Expect(atomic.LoadInt32(&produced)).To(Equal(int32(2)))
+	})
+
+	It("should expire values in both tiers", func() {
+		c := open(0)
+		c.TTL = time.Minute
+		Expect(c.Value("a")).To(Equal("a1<>"))
+
+		clock.Advance(time.Minute)
+		c = open(0)
+		c.TTL = time.Minute
+		Expect(c.Value("a")).To(Equal("a2<a1<>>"))
+		Expect(c.Value("a")).To(Equal("a2<a1<>>"))
+
+		clock.Advance(time.Minute)
+		Expect(c.Value("a")).To(Equal("a3<a2<a1<>>>"))
+	})
+
+	It("should produce once for concurrent misses", func() {
+		c := open(0)
+		release := make(chan struct{})
+		c.Producer = func(key string, cached string, args ...any) (string, error) {
+			<-release
+			return produce(key, cached, args...)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(c.Value("a")).To(Equal("a1<>"))
+			}()
+		}
+		Consistently(func() int32 { return atomic.LoadInt32(&produced) }, 50*time.Millisecond).Should(Equal(int32(0)))
+		close(release)
+		wg.Wait()
+		Expect(atomic.LoadInt32(&produced)).To(Equal(int32(1)))
+	})
+
+	It("should retry failed productions", func() {
+		c := open(0)
+		errProduce := errors.New("produce")
+		c.Producer = func(key string, cached string, args ...any) (string, error) {
+			return "", errProduce
+		}
+		_, err := c.ValueWithError("a")
+		Expect(err).To(Equal(errProduce))
+
+		c.Producer = func(key string, cached string, args ...any) (string, error) {
+			panic("produce")
+		}
+		Expect(func() { c.Value("a") }).To(Panic())
+		Expect(files()).To(BeEmpty())
+
+		c.Producer = produce
+		Expect(c.Value("a")).To(Equal("a1<>"))
+	})
+
+	It("should invalidate values in both tiers", func() {
+		c := open(0)
+		c.Value("a")
+		Expect(c.Invalidate("a")).To(Succeed())
+		Expect(files()).To(BeEmpty())
+		Expect(c.Value("a")).To(Equal("a2<>"))
+	})
+
+	It("should bound the size on disk", func() {
+		c := open(0)
+		c.Value("a")
+		size := c.DiskBytes()
+		Expect(size).To(BeNumerically(">", 0))
+
+		c.MaxDiskBytes = 2 * size
+		clock.Advance(time.Second)
+		c.Value("b")
+		clock.Advance(time.Second)
+		c.Value("c")
+		Expect(files()).To(HaveLen(2))
+		Expect(c.DiskBytes()).To(BeNumerically("<=", 2*size))
+
+		c = open(0)
+		c.Value("a")
+		Expect(atomic.LoadInt32(&produced)).To(Equal(int32(4)))
+	})
+
+	It("should compact expired and corrupted files", func() {
+		c := open(0)
+		c.TTL = time.Minute
+		c.Value("a")
+		c.TTL = 0
+		c.Value("b")
+		Expect(os.WriteFile(filepath.Join(dir, "corrupted.cache"), []byte("corrupted"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, ".tmp-interrupted"), []byte("partial"), 0644)).To(Succeed())
+
+		c = open(0)
+		Expect(files()).To(HaveLen(3))
+		clock.Advance(time.Minute)
+		Expect(c.Compact()).To(Succeed())
+		Expect(files()).To(HaveLen(1))
+		Expect(c.Value("b")).To(Equal("b2<>"))
+	})
+
+	It("should serve hits while writing other values to disk", func() {
+		codec := &blockingCodec{Codec: cache.GobCodec, started: make(chan struct{}), release: make(chan struct{})}
+		c := open(0)
+		c.Codec = codec
+		Expect(c.Value("fast")).To(Equal("fast1<>"))
+
+		done := make(chan string)
+		go func() {
+			done <- c.Value("slow")
+		}()
+		<-codec.started
+		Expect(c.Value("fast")).To(Equal("fast1<>"))
+		Expect(c.DiskBytes()).To(BeNumerically(">", 0))
+
+		close(codec.release)
+		Eventually(done).Should(Receive(Equal("slow2<>")))
+		Expect(files()).To(HaveLen(2))
+	})
+
+	It("should encode by the codec", func() {
+		logger := &recordLogger{}
+		c := open(0)
+		c.Codec = cache.JSONCodec
+		c.Logger = logger
+		c.Value("a")
+		data, err := os.ReadFile(filepath.Join(dir, files()[0]))
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(ContainSubstring(`"Key":"a"`))
+
+		c = open(0)
+		c.Logger = logger
+		Expect(c.Value("a")).To(Equal("a2<>"))
+		Expect(logger.Warns()).To(HaveLen(1))
+		Expect(logger.Warns()[0]).To(HavePrefix("Failed to decode cached value of a, removing"))
+	})
+})
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// registerAliases registers former names of the option to share the flag of the option.
+func (o *options) registerAliases(field *optionField, f *flag.Flag, tag reflect.StructTag) error {
+	field.deprecated = tag.Get(OptionDeprecated)
+	aliases := tag.Get(OptionAlias)
+	if aliases == "" {
+		return nil
+	}
+
+	for _, alias := range strings.Split(aliases, ",") {
+		alias = strings.TrimSpace(alias)
+		if strings.HasPrefix(alias, "$") {
+			field.envAliases = append(field.envAliases, alias[1:])
+			continue
+		} else if alias == "" {
+			continue
+		} else if o.flags.Lookup(alias) != nil {
+			return fmt.Errorf("alias \"%s\" of option \"%s\" is defined already", alias, field.name)
+		}
+		o.flags.Var(f.Value, alias, fmt.Sprintf("Deprecated, use -%s.", field.name))
+		o.flags.Lookup(alias).DefValue = f.DefValue
+		field.aliases = append(field.aliases, alias)
+	}
+	return nil
+}
+
+// aliases maps former names of options to the options.
+func (o *options) aliases() map[string]*optionField {
+	aliases := make(map[string]*optionField)
+	for _, field := range o.fields {
+		for _, alias := range field.aliases {
+			aliases[alias] = field
+		}
+	}
+	return aliases
+}
+
+// aliased returns true if the option has former names.
+func (f *optionField) aliased() bool {
+	return len(f.aliases) > 0 || len(f.envAliases) > 0
+}
+
+// lookupEnv looks up the environment variable of the option, then former ones.
+func lookupEnv(field *optionField) (string, string, bool) {
+	if field.env != "" {
+		if val, ok := os.LookupEnv(field.env); ok {
+			return field.env, val, true
+		}
+	}
+	for _, env := range field.envAliases {
+		if val, ok := os.LookupEnv(env); ok {
+			hint := "-" + field.name
+			if field.env != "" {
+				hint = "$" + field.env
+			}
+			warnDeprecated(field, "$"+env, hint)
+			return env, val, true
+		}
+	}
+	return "", "", false
+}
+
+// warnDeprecated warns that the option is set by a deprecated name, with the hint of the option
+// or the name to use instead.
+func warnDeprecated(field *optionField, name string, instead string) {
+	hint := field.deprecated
+	if hint == "" {
+		hint = "use " + instead
+	}
+	GetLogger(LogConfig).Warn("Option %s is deprecated, %s.", name, hint)
+}
@@ -0,0 +1,113 @@
+package config_test
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MyAliasConfig struct {
+	config.Options
+
+	Name    string `name:"name" alias:"title,$MY_ALIAS_TITLE" env:"MY_ALIAS_NAME" description:"Option \"name\"."`
+	Count   int    `name:"count" alias:"num" deprecated:"use -count instead of -num" description:"Option \"count\"."`
+	Legacy  bool   `name:"legacy" deprecated:"legacy mode will be removed" description:"Option \"legacy\"."`
+	Replica int    `name:"replica" default:"1" description:"Option \"replica\"."`
+}
+
+type MyConflictAliasConfig struct {
+	config.Options
+
+	Name  string `name:"name" description:"Option \"name\"."`
+	Title string `name:"title" alias:"name" description:"Option \"title\"."`
+}
+
+var _ = Describe("Alias", func() {
+	var logs bytes.Buffer
+
+	BeforeEach(func() {
+		logs.Reset()
+		log.SetOutput(&logs)
+	})
+
+	AfterEach(func() {
+		log.SetOutput(os.Stderr)
+	})
+
+	It("should accept aliases from flags with warnings", func() {
+		var cfg MyAliasConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-title=Elle", "-num=2")).To(Succeed())
+		Expect(cfg.Name).To(Equal("Elle"))
+		Expect(cfg.Count).To(Equal(2))
+		Expect(logs.String()).To(ContainSubstring("Option -title is deprecated, use -name."))
+		Expect(logs.String()).To(ContainSubstring("Option -num is deprecated, use -count instead of -num."))
+
+		settings, err := config.Effective(&cfg)
+		Expect(err).To(BeNil())
+		Expect(settings[2].Name).To(Equal("name"))
+		Expect(settings[2].Origin).To(Equal(config.OriginFlag))
+	})
+
+	It("should not warn on new names", func() {
+		var cfg MyAliasConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-name=Elle", "-count=2", "-replica=3")).To(Succeed())
+		Expect(cfg.Name).To(Equal("Elle"))
+		Expect(logs.String()).NotTo(ContainSubstring("deprecated"))
+	})
+
+	It("should accept aliases of environment variables", func() {
+		os.Setenv("MY_ALIAS_TITLE", "Env")
+		defer os.Unsetenv("MY_ALIAS_TITLE")
+
+		var cfg MyAliasConfig
+		Expect(config.NewLoader("test").Load(&cfg)).To(Succeed())
+		Expect(cfg.Name).To(Equal("Env"))
+		Expect(logs.String()).To(ContainSubstring("Option $MY_ALIAS_TITLE is deprecated, use $MY_ALIAS_NAME."))
+
+		os.Setenv("MY_ALIAS_NAME", "New")
+		defer os.Unsetenv("MY_ALIAS_NAME")
+		Expect(config.NewLoader("test").Load(&cfg)).To(Succeed())
+		Expect(cfg.Name).To(Equal("New"))
+	})
+
+	It("should accept aliases from config files", func() {
+		dir, err := os.MkdirTemp("", "config")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "config.yml")
+		Expect(os.WriteFile(path, []byte("title: File\nnum: 4\n"), 0644)).To(Succeed())
+
+		var cfg MyAliasConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path)).To(Succeed())
+		Expect(cfg.Name).To(Equal("File"))
+		Expect(cfg.Count).To(Equal(4))
+		Expect(logs.String()).To(ContainSubstring("Option \"title\" in " + path + " is deprecated, use name."))
+	})
+
+	It("should prefer new names over aliases in sources", func() {
+		var cfg MyAliasConfig
+		source := config.NewMapSource("map", map[string]interface{}{"title": "Old", "name": "New"})
+		Expect(config.NewLoader("test", source).Load(&cfg)).To(Succeed())
+		Expect(cfg.Name).To(Equal("New"))
+		Expect(logs.String()).NotTo(ContainSubstring("deprecated"))
+	})
+
+	It("should warn on deprecated options", func() {
+		var cfg MyAliasConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-legacy")).To(Succeed())
+		Expect(cfg.Legacy).To(BeTrue())
+		Expect(logs.String()).To(ContainSubstring("Option -legacy is deprecated, legacy mode will be removed."))
+	})
+
+	It("should fail on conflicting aliases", func() {
+		var cfg MyConflictAliasConfig
+		err := config.NewLoader("test").Register(&cfg)
+		Expect(err).To(MatchError(config.ErrInvalidOptions))
+		Expect(err.Error()).To(ContainSubstring("alias \"name\" of option \"title\" is defined already"))
+	})
+})
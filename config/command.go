@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+// Command is a node of a command tree for options-driven CLIs, e.g. "tool serve" and "tool bench".
+//
+// Each command has options of its own and inherits options of its ancestors: flags of ancestors
+// can also be specified after the name of the command, and the config file specified at any
+// level applies to all levels unless specified otherwise.
+type Command struct {
+	// Name is the name of the command. The name of the root command is the name of the program.
+	Name string
+
+	// Description is shown in the help of the command.
+	Description string
+
+	// Options of the command. NewOptions is used if nil.
+	Options Options
+
+	// Run runs the command with validated options and remaining arguments.
+	// A command without Run requires a subcommand.
+	Run func(opts Options, args []string) error
+
+	// Commands are subcommands of the command.
+	Commands []*Command
+
+	// Output is where the help is printed. Only the Output of the root command is used.
+	// Defaults to os.Stderr.
+	Output io.Writer
+}
+
+// invocation is a command on the path being executed.
+type invocation struct {
+	cmd    *Command
+	name   string
+	opts   Options
+	loader *Loader
+}
+
+// Execute executes the command tree with command line arguments.
+// If returns ErrPrintUsage, the help of the command has been printed.
+func (c *Command) Execute() error {
+	return c.ExecuteWithArgs(os.Args[1:]...)
+}
+
+// ExecuteWithArgs parses arguments level by level, dispatches to the subcommand named by the
+// first non-flag argument, validates options from the root to the subcommand, and runs it.
+// If returns ErrPrintUsage, the help of the command has been printed.
+func (c *Command) ExecuteWithArgs(args ...string) error {
+	var path []*invocation
+	cmd, name := c, c.Name
+	for {
+		inv, err := c.invoke(cmd, name, path)
+		if err != nil {
+			return err
+		}
+		path = append(path, inv)
+
+		flags := inv.loader.FlagSet()
+		usage := path
+		flags.Usage = func() { c.writeUsage(flags.Output(), usage) }
+		if err := flags.Parse(args); err != nil {
+			return err
+		} else if inv.loader.help {
+			flags.Usage()
+			return ErrPrintUsage
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		sub := cmd.lookup(args[0])
+		if sub == nil {
+			break
+		}
+		cmd, name, args = sub, name+" "+sub.Name, args[1:]
+	}
+
+	if cmd.Run == nil {
+		if len(args) > 0 {
+			return fmt.Errorf("%w: %s %s", ErrUnknownCommand, name, args[0])
+		}
+		path[len(path)-1].loader.FlagSet().Usage()
+		return ErrPrintUsage
+	}
+
+	shareFlags(path)
+	for _, inv := range path {
+		if err := inv.loader.Resolve(); err != nil {
+			return err
+		}
+	}
+
+	leaf := path[len(path)-1]
+	return cmd.Run(leaf.opts, args)
+}
+
+func (c *Command) invoke(cmd *Command, name string, ancestors []*invocation) (*invocation, error) {
+	inv := &invocation{cmd: cmd, name: name, opts: cmd.Options, loader: NewLoader(name)}
+	if inv.opts == nil {
+		inv.opts = NewOptions()
+	}
+	if err := inv.loader.Register(inv.opts); err != nil {
+		return nil, err
+	}
+
+	flags := inv.loader.FlagSet()
+	flags.SetOutput(c.output())
+	// Inherit flags of ancestors by sharing their values. Options of the command take precedence.
+	for _, ancestor := range ancestors {
+		ancestor.loader.FlagSet().VisitAll(func(f *flag.Flag) {
+			if flags.Lookup(f.Name) == nil {
+				flags.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+	}
+	return inv, nil
+}
+
+func (c *Command) lookup(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (c *Command) output() io.Writer {
+	if c.Output == nil {
+		return os.Stderr
+	}
+	return c.Output
+}
+
+// shareFlags marks flags specified at descendants as set at ancestors, and shares the config file
+// and profile specified at any level with levels specifying none.
+func shareFlags(path []*invocation) {
+	for i := len(path) - 1; i > 0; i-- {
+		path[i].loader.FlagSet().Visit(func(f *flag.Flag) {
+			for _, ancestor := range path[:i] {
+				if inherited := ancestor.loader.FlagSet().Lookup(f.Name); inherited != nil && inherited.Value == f.Value {
+					ancestor.loader.FlagSet().Set(f.Name, f.Value.String())
+				}
+			}
+		})
+	}
+
+	shareOption(path, "yaml", func(meta *options) string { return meta.YAML })
+	shareOption(path, "profile", func(meta *options) string { return meta.Profile })
+}
+
+// shareOption sets the option of the config file, specified at the deepest level, to levels specifying none.
+func shareOption(path []*invocation, name string, get func(*options) string) {
+	var val string
+	for i := len(path) - 1; i >= 0 && val == ""; i-- {
+		val = get(path[i].opts.meta())
+	}
+	if val == "" {
+		return
+	}
+	for _, inv := range path {
+		if get(inv.opts.meta()) == "" {
+			inv.loader.FlagSet().Set(name, val)
+		}
+	}
+}
+
+func (c *Command) writeUsage(w io.Writer, path []*invocation) {
+	inv := path[len(path)-1]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage: %s [options]", inv.name)
+	if len(inv.cmd.Commands) > 0 {
+		b.WriteString(" <command>")
+	}
+	b.WriteString(" [args]\n")
+	if inv.cmd.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", inv.cmd.Description)
+	}
+	if len(inv.cmd.Commands) > 0 {
+		b.WriteString("\nCommands:\n")
+		for _, sub := range inv.cmd.Commands {
+			fmt.Fprintf(&b, "  %s\t%s\n", sub.Name, sub.Description)
+		}
+	}
+
+	b.WriteString("\nOptions:\n  -h\tShow help.\n")
+	groups, fields := groupFields(inv.opts.meta().fields)
+	writeTextGroups(&b, groups, fields)
+	for i := len(path) - 2; i >= 0; i-- {
+		var inherited []*optionField
+		for _, field := range path[i].opts.meta().fields {
+			if f := inv.loader.FlagSet().Lookup(field.name); f != nil && f.Value == path[i].loader.FlagSet().Lookup(field.name).Value {
+				inherited = append(inherited, field)
+			}
+		}
+		if len(inherited) > 0 {
+			fmt.Fprintf(&b, "\nOptions inherited from %s:\n", path[i].name)
+			groups, fields := groupFields(inherited)
+			writeTextGroups(&b, groups, fields)
+		}
+	}
+	io.WriteString(w, b.String())
+}
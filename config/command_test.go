@@ -0,0 +1,121 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MyGlobalConfig struct {
+	config.Options
+	config.LoggerOptions
+	config.SeedOptions
+}
+
+// Validate resolves the ambiguity of embedded options, which are validated respectively.
+func (c *MyGlobalConfig) Validate() error {
+	return nil
+}
+
+type MyServeConfig struct {
+	config.Options
+
+	Port int `name:"port" default:"8080" description:"Port to listen on."`
+}
+
+type MyBenchConfig struct {
+	config.Options
+
+	Rounds int `name:"rounds" default:"1" description:"Rounds to run."`
+}
+
+var _ = Describe("Command", func() {
+	var global *MyGlobalConfig
+	var serve *MyServeConfig
+	var bench *MyBenchConfig
+	var output *bytes.Buffer
+	var ran string
+	var ranArgs []string
+	var root *config.Command
+
+	BeforeEach(func() {
+		global, serve, bench = &MyGlobalConfig{}, &MyServeConfig{}, &MyBenchConfig{}
+		output = &bytes.Buffer{}
+		ran, ranArgs = "", nil
+		run := func(name string) func(config.Options, []string) error {
+			return func(opts config.Options, args []string) error {
+				ran, ranArgs = name, args
+				return nil
+			}
+		}
+		root = &config.Command{
+			Name:        "tool",
+			Description: "Tool for tests.",
+			Options:     global,
+			Output:      output,
+			Commands: []*config.Command{
+				{Name: "serve", Description: "Serve requests.", Options: serve, Run: run("serve")},
+				{Name: "bench", Description: "Run benchmarks.", Options: bench, Run: run("bench")},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		config.LogLevel = config.DefaultLogLevel
+	})
+
+	It("should dispatch to the subcommand", func() {
+		Expect(root.ExecuteWithArgs("-seed=1", "serve", "-port=80", "extra")).To(Succeed())
+		Expect(ran).To(Equal("serve"))
+		Expect(ranArgs).To(Equal([]string{"extra"}))
+		Expect(global.Seed).To(Equal(int64(1)))
+		Expect(serve.Port).To(Equal(80))
+	})
+
+	It("should pass validated options of the subcommand to run", func() {
+		root.Commands[1].Run = func(opts config.Options, args []string) error {
+			Expect(opts).To(BeIdenticalTo(bench))
+			return errors.New("ran")
+		}
+		Expect(root.ExecuteWithArgs("bench")).To(MatchError("ran"))
+		Expect(bench.Rounds).To(Equal(1))
+	})
+
+	It("should accept global options after the subcommand", func() {
+		Expect(root.ExecuteWithArgs("bench", "-debug", "-rounds=3")).To(Succeed())
+		Expect(ran).To(Equal("bench"))
+		Expect(global.Debug).To(Equal(true))
+		Expect(bench.Rounds).To(Equal(3))
+	})
+
+	It("should share the config file with all levels", func() {
+		Expect(root.ExecuteWithArgs("serve", "-yaml=options_test.yml")).To(Succeed())
+		Expect(global.Debug).To(Equal(true))
+	})
+
+	It("should print per-command help", func() {
+		Expect(root.ExecuteWithArgs("serve", "-h")).To(Equal(config.ErrPrintUsage))
+		Expect(ran).To(Equal(""))
+
+		usage := output.String()
+		Expect(usage).To(HavePrefix("Usage: tool serve [options] [args]\n\nServe requests.\n"))
+		Expect(usage).To(ContainSubstring("\nMyServeConfig:\n  -port int\n"))
+		Expect(usage).To(ContainSubstring("\nOptions inherited from tool:\n"))
+		Expect(usage).To(ContainSubstring("\nLoggerOptions:\n  -debug bool\n"))
+		Expect(usage).To(ContainSubstring("\nSeedOptions:\n  -seed int64\n"))
+	})
+
+	It("should print help listing subcommands if no subcommand specified", func() {
+		Expect(root.ExecuteWithArgs()).To(Equal(config.ErrPrintUsage))
+		Expect(output.String()).To(HavePrefix("Usage: tool [options] <command> [args]\n\nTool for tests.\n\nCommands:\n  serve\tServe requests.\n  bench\tRun benchmarks.\n"))
+	})
+
+	It("should fail on unknown subcommand", func() {
+		err := root.ExecuteWithArgs("replay")
+		Expect(errors.Is(err, config.ErrUnknownCommand)).To(Equal(true))
+		Expect(err.Error()).To(Equal("unknown command: tool replay"))
+	})
+})
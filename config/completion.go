@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// WriteCompletion writes the completion script of the options for the shell: "bash", "zsh" or "fish".
+// Names and descriptions of options are completed, along with values listed by the "oneof" tag and
+// paths of options tagged by `complete:"file"` or `complete:"dir"`, e.g. "-yaml".
+// The options must have been registered by a Loader, ValidateOptions or ValidateOptionsWithFlags.
+//
+// To enable completion, e.g. in bash:
+//
+//	source <(tool -completion=bash)
+func WriteCompletion(w io.Writer, opts Options, shell string) error {
+	meta, err := metaOf(opts)
+	if err != nil {
+		return err
+	}
+
+	prog := meta.flags.Name()
+	if prog == "" && len(os.Args) > 0 {
+		prog = os.Args[0]
+	}
+	prog = filepath.Base(prog)
+
+	var b strings.Builder
+	switch shell {
+	case "bash":
+		writeBashCompletion(&b, prog, meta.fields)
+	case "zsh":
+		writeZshCompletion(&b, prog, meta.fields)
+	case "fish":
+		writeFishCompletion(&b, prog, meta.fields)
+	default:
+		return fmt.Errorf("unsupported shell: %s", shell)
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func writeBashCompletion(b *strings.Builder, prog string, fields []*optionField) {
+	fn := "_" + identifier(prog)
+	fmt.Fprintf(b, "%s() {\n", fn)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	// "=" breaks words, complete "-name=value" like "-name value".
+	b.WriteString("    if [[ \"$cur\" == \"=\" ]]; then\n        cur=\"\"\n")
+	b.WriteString("    elif [[ \"$prev\" == \"=\" && $COMP_CWORD -gt 1 ]]; then\n        prev=\"${COMP_WORDS[COMP_CWORD-2]}\"\n    fi\n")
+
+	b.WriteString("    case \"$prev\" in\n")
+	for _, field := range fields {
+		if field.kind == reflect.Bool {
+			continue
+		}
+		fmt.Fprintf(b, "    -%s)\n", field.name)
+		switch {
+		case len(field.oneof) > 0:
+			fmt.Fprintf(b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(field.oneof, " "))
+		case field.complete == "file":
+			b.WriteString("        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+		case field.complete == "dir":
+			b.WriteString("        COMPREPLY=($(compgen -d -- \"$cur\"))\n")
+		default:
+			b.WriteString("        COMPREPLY=()\n")
+		}
+		b.WriteString("        return\n        ;;\n")
+	}
+	b.WriteString("    esac\n")
+
+	names := []string{"-h"}
+	for _, field := range fields {
+		names = append(names, "-"+field.name)
+	}
+	fmt.Fprintf(b, "    COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString("}\n\n")
+	fmt.Fprintf(b, "complete -o default -F %s %s\n", fn, prog)
+}
+
+func writeZshCompletion(b *strings.Builder, prog string, fields []*optionField) {
+	fn := "_" + identifier(prog)
+	fmt.Fprintf(b, "#compdef %s\n\n", prog)
+	fmt.Fprintf(b, "%s() {\n", fn)
+	b.WriteString("    _arguments \\\n")
+	b.WriteString("        '-h[Show help.]'")
+	for _, field := range fields {
+		b.WriteString(" \\\n        ")
+		desc := zshEscape(field.desc)
+		if field.kind == reflect.Bool {
+			fmt.Fprintf(b, "'-%s[%s]'", field.name, desc)
+			continue
+		}
+
+		action := ""
+		switch {
+		case len(field.oneof) > 0:
+			action = "(" + zshEscape(strings.Join(field.oneof, " ")) + ")"
+		case field.complete == "file":
+			action = "_files"
+		case field.complete == "dir":
+			action = "_files -/"
+		}
+		fmt.Fprintf(b, "'-%s=[%s]:%s:%s'", field.name, desc, field.name, action)
+	}
+	b.WriteString("\n}\n\n")
+	fmt.Fprintf(b, "if [ \"$funcstack[1]\" = \"%s\" ]; then\n    %s \"$@\"\nelse\n    compdef %s %s\nfi\n", fn, fn, fn, prog)
+}
+
+func writeFishCompletion(b *strings.Builder, prog string, fields []*optionField) {
+	fmt.Fprintf(b, "complete -c %s -o h -d 'Show help.'\n", prog)
+	for _, field := range fields {
+		fmt.Fprintf(b, "complete -c %s -o %s", prog, field.name)
+		switch {
+		case field.kind == reflect.Bool:
+		case len(field.oneof) > 0:
+			fmt.Fprintf(b, " -x -a '%s'", fishEscape(strings.Join(field.oneof, " ")))
+		case field.complete == "file":
+			b.WriteString(" -r -F")
+		case field.complete == "dir":
+			b.WriteString(" -x -a '(__fish_complete_directories)'")
+		default:
+			b.WriteString(" -x")
+		}
+		fmt.Fprintf(b, " -d '%s'\n", fishEscape(field.desc))
+	}
+}
+
+// identifier converts the name of the program to a valid name of shell functions.
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+var zshReplacer = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+func zshEscape(s string) string {
+	return zshReplacer.Replace(s)
+}
+
+var fishReplacer = strings.NewReplacer(`\`, `\\`, "'", `\'`)
+
+func fishEscape(s string) string {
+	return fishReplacer.Replace(s)
+}
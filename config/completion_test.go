@@ -0,0 +1,90 @@
+package config_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MyCompletionConfig struct {
+	config.Options
+
+	Test bool   `name:"test" description:"Option \"test\"."`
+	Mode string `name:"mode" default:"fast" oneof:"fast,slow" description:"Mode: fast or slow."`
+	Dir  string `name:"dir" complete:"dir" description:"Option \"dir\"."`
+}
+
+var _ = Describe("Completion", func() {
+	var cfg MyCompletionConfig
+
+	BeforeEach(func() {
+		cfg = MyCompletionConfig{}
+		Expect(config.NewLoader("tool").Load(&cfg)).To(Succeed())
+	})
+
+	It("should write bash completion", func() {
+		var buf bytes.Buffer
+		Expect(config.WriteCompletion(&buf, &cfg, "bash")).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("    -yaml)\n        COMPREPLY=($(compgen -f -- \"$cur\"))\n"))
+		Expect(buf.String()).To(ContainSubstring("    -mode)\n        COMPREPLY=($(compgen -W \"fast slow\" -- \"$cur\"))\n"))
+		Expect(buf.String()).To(ContainSubstring("    -dir)\n        COMPREPLY=($(compgen -d -- \"$cur\"))\n"))
+		Expect(buf.String()).NotTo(ContainSubstring("    -test)"))
+		Expect(buf.String()).To(ContainSubstring("compgen -W \"-h -yaml -profile -test -mode -dir\""))
+		Expect(buf.String()).To(HaveSuffix("complete -o default -F _tool tool\n"))
+	})
+
+	It("should write zsh completion", func() {
+		var buf bytes.Buffer
+		Expect(config.WriteCompletion(&buf, &cfg, "zsh")).To(Succeed())
+		Expect(buf.String()).To(HavePrefix("#compdef tool\n"))
+		Expect(buf.String()).To(ContainSubstring("'-yaml=[Path to config file in the yml format.]:yaml:_files'"))
+		Expect(buf.String()).To(ContainSubstring("'-test[Option \"test\".]'"))
+		Expect(buf.String()).To(ContainSubstring("'-mode=[Mode\\: fast or slow.]:mode:(fast slow)'"))
+		Expect(buf.String()).To(ContainSubstring("'-dir=[Option \"dir\".]:dir:_files -/'"))
+	})
+
+	It("should write fish completion", func() {
+		var buf bytes.Buffer
+		Expect(config.WriteCompletion(&buf, &cfg, "fish")).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("complete -c tool -o yaml -r -F -d 'Path to config file in the yml format.'\n"))
+		Expect(buf.String()).To(ContainSubstring("complete -c tool -o test -d 'Option \"test\".'\n"))
+		Expect(buf.String()).To(ContainSubstring("complete -c tool -o mode -x -a 'fast slow' -d 'Mode: fast or slow.'\n"))
+	})
+
+	It("should fail on unsupported shells", func() {
+		Expect(config.WriteCompletion(io.Discard, &cfg, "tcsh")).To(MatchError("unsupported shell: tcsh"))
+	})
+
+	It("should print completion by the hidden flag", func() {
+		stdout := os.Stdout
+		r, w, err := os.Pipe()
+		Expect(err).To(BeNil())
+		os.Stdout = w
+		defer func() { os.Stdout = stdout }()
+
+		var cfg MyCompletionConfig
+		_, err = config.ValidateOptionsWithFlags(&cfg, "-completion=fish")
+		w.Close()
+		Expect(err).To(Equal(config.ErrPrintCompletion))
+
+		out, err := io.ReadAll(r)
+		Expect(err).To(BeNil())
+		Expect(string(out)).To(ContainSubstring("-o mode -x -a 'fast slow'"))
+
+		var buf bytes.Buffer
+		Expect(config.WriteUsage(&buf, &cfg, config.UsageText)).To(Succeed())
+		Expect(buf.String()).NotTo(ContainSubstring("completion"))
+		Expect(buf.String()).To(ContainSubstring("(default \"fast\", one of fast|slow, yaml mode)"))
+	})
+
+	It("should reject values not listed by oneof", func() {
+		var cfg MyCompletionConfig
+		err := config.NewLoader("tool").Load(&cfg, "-mode=medium")
+		Expect(err).To(MatchError("invalid value \"medium\" for \"mode\": must be one of fast, slow"))
+		Expect(config.NewLoader("tool").Load(&cfg, "-mode=slow")).To(Succeed())
+	})
+})
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Origin is where the effective value of an option comes from.
+type Origin string
+
+const (
+	// OriginDefault means the value is the default, either in code or by the "default" tag.
+	OriginDefault Origin = "default"
+	// OriginFlag means the value is set by a command line flag.
+	OriginFlag Origin = "flag"
+	// OriginEnv means the value is set by an environment variable.
+	OriginEnv Origin = "env"
+	// OriginFile means the value is set by the config file specified by "-yaml".
+	OriginFile Origin = "file"
+	// OriginSource means the value is set by one of the Sources of the Loader.
+	OriginSource Origin = "source"
+	// OriginComputed means the value is changed by validation, e.g. a random seed generated by SeedOptions.
+	OriginComputed Origin = "computed"
+)
+
+// DumpFormat is the output format of the effective options.
+type DumpFormat int
+
+const (
+	// DumpYAML writes the effective options in the yml format, which can be loaded by "-yaml".
+	// Origins are written as comments.
+	DumpYAML DumpFormat = iota
+	// DumpJSON writes the effective options as a JSON object.
+	DumpJSON
+)
+
+// Setting is the effective value of an option and where the value comes from.
+type Setting struct {
+	// Name is the name of the option, which is also the flag name and the key in config files.
+	Name string
+
+	// Group is the name of the options struct declared the option.
+	Group string
+
+	// Value is the effective value.
+	Value interface{}
+
+	// Origin is where the value comes from.
+	Origin Origin
+
+	// Source names the environment variable, file or Source the value comes from.
+	Source string
+
+	// Secret is true if the option is tagged secret, of which the Value is redacted.
+	Secret bool
+
+	// Ref is the reference the value of a secret option is resolved from, e.g. "file:///run/secrets/key".
+	Ref string
+}
+
+func (s *Setting) String() string {
+	if s.Source == "" {
+		return fmt.Sprintf("%s=%v (%s)", s.Name, s.Value, s.Origin)
+	}
+	return fmt.Sprintf("%s=%v (%s %s)", s.Name, s.Value, s.Origin, s.Source)
+}
+
+// Effective returns effective values of the options in order of registration, along with their origins.
+// The options must have been resolved by a Loader, ValidateOptions or ValidateOptionsWithFlags.
+func Effective(opts Options) ([]*Setting, error) {
+	meta, err := metaOf(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make([]*Setting, len(meta.fields))
+	for i, field := range meta.fields {
+		settings[i] = &Setting{
+			Name:   field.name,
+			Group:  field.group,
+			Value:  field.value.Interface(),
+			Origin: field.origin,
+			Source: field.source,
+			Secret: field.secret,
+			Ref:    field.ref,
+		}
+		if field.secret && field.value.String() != "" {
+			settings[i].Value = Redacted
+		} else if field.origin == OriginDefault && fmt.Sprintf("%v", settings[i].Value) != field.defValue {
+			settings[i].Origin = OriginComputed
+		}
+	}
+	return settings, nil
+}
+
+// Dump writes the effective options in specified format, so that a run can be reproduced
+// by loading the output as the config file. The path of the config file itself is omitted,
+// so is the profile if none is selected.
+// Secret options are written as their references if any, or redacted otherwise.
+func Dump(w io.Writer, opts Options, format DumpFormat) error {
+	settings, err := Effective(opts)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case DumpYAML:
+		return dumpYAML(w, settings)
+	case DumpJSON:
+		return dumpJSON(w, settings)
+	default:
+		return fmt.Errorf("unsupported dump format: %d", format)
+	}
+}
+
+func dumpYAML(w io.Writer, settings []*Setting) error {
+	var b strings.Builder
+	for _, setting := range settings {
+		if omitDump(setting) {
+			continue
+		}
+
+		line, err := yaml.Marshal(yaml.MapSlice{{Key: setting.Name, Value: dumpValue(setting)}})
+		if err != nil {
+			return err
+		}
+		b.WriteString(strings.TrimSuffix(string(line), "\n"))
+		b.WriteString(" # ")
+		b.WriteString(string(setting.Origin))
+		if setting.Source != "" {
+			b.WriteString(" ")
+			b.WriteString(setting.Source)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dumpJSON(w io.Writer, settings []*Setting) error {
+	values := make(map[string]interface{}, len(settings))
+	for _, setting := range settings {
+		if !omitDump(setting) {
+			values[setting.Name] = dumpValue(setting)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+func dumpValue(setting *Setting) interface{} {
+	if setting.Secret && setting.Ref != "" {
+		return setting.Ref
+	}
+	return setting.Value
+}
+
+func omitDump(setting *Setting) bool {
+	return setting.Name == "yaml" || (setting.Name == "profile" && setting.Value == "")
+}
@@ -0,0 +1,109 @@
+package config_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MyEffectiveConfig struct {
+	config.Options
+	config.SeedOptions
+
+	Test  bool   `name:"test" description:"Option \"test\"."`
+	Name  string `name:"name" description:"Option \"name\"."`
+	Count int    `name:"count" default:"3" env:"MY_EFFECTIVE_COUNT" description:"Option \"count\"."`
+	Label string `name:"label" default:"none" description:"Option \"label\"."`
+}
+
+func (c *MyEffectiveConfig) Validate() error {
+	return nil
+}
+
+var _ = Describe("Effective", func() {
+	BeforeEach(func() {
+		os.Setenv("MY_EFFECTIVE_COUNT", "7")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("MY_EFFECTIVE_COUNT")
+	})
+
+	It("should report origins of effective values", func() {
+		var cfg MyEffectiveConfig
+		loader := config.NewLoader("test", config.NewMapSource("remote", map[string]interface{}{"label": "remote"}))
+		Expect(loader.Load(&cfg, "-yaml=options_test.yml", "-test=false")).To(Succeed())
+
+		settings, err := config.Effective(&cfg)
+		Expect(err).To(BeNil())
+		origins := make(map[string]string)
+		for _, setting := range settings {
+			origins[setting.Name] = string(setting.Origin) + " " + setting.Source
+		}
+		Expect(origins).To(Equal(map[string]string{
+			"yaml":    "flag ",
+			"profile": "default ",
+			"seed":    "computed ",
+			"test":    "flag ",
+			"name":    "file options_test.yml",
+			"count":   "env MY_EFFECTIVE_COUNT",
+			"label":   "source remote",
+		}))
+		Expect(settings[2].String()).To(Equal(fmt.Sprintf("seed=%d (computed)", cfg.Seed)))
+	})
+
+	It("should dump yaml that reproduces the run", func() {
+		var cfg MyEffectiveConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml=options_test.yml")).To(Succeed())
+
+		var buf bytes.Buffer
+		Expect(config.Dump(&buf, &cfg, config.DumpYAML)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("name: Tianium # file options_test.yml\n"))
+		Expect(buf.String()).To(ContainSubstring("count: 7 # env MY_EFFECTIVE_COUNT\n"))
+		Expect(buf.String()).NotTo(ContainSubstring("yaml:"))
+
+		dir, err := os.MkdirTemp("", "config")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "effective.yml")
+		Expect(os.WriteFile(path, buf.Bytes(), 0644)).To(Succeed())
+
+		os.Unsetenv("MY_EFFECTIVE_COUNT")
+		var reproduced MyEffectiveConfig
+		Expect(config.NewLoader("test").Load(&reproduced, "-yaml="+path)).To(Succeed())
+		Expect(reproduced.Seed).To(Equal(cfg.Seed))
+		Expect(reproduced.Test).To(Equal(cfg.Test))
+		Expect(reproduced.Name).To(Equal(cfg.Name))
+		Expect(reproduced.Count).To(Equal(7))
+		Expect(reproduced.Label).To(Equal(cfg.Label))
+	})
+
+	It("should dump json", func() {
+		var cfg MyEffectiveConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-seed=42", "-name=Elle")).To(Succeed())
+
+		var buf bytes.Buffer
+		Expect(config.Dump(&buf, &cfg, config.DumpJSON)).To(Succeed())
+		var values map[string]interface{}
+		Expect(json.Unmarshal(buf.Bytes(), &values)).To(Succeed())
+		Expect(values).To(Equal(map[string]interface{}{
+			"seed":  42.0,
+			"test":  false,
+			"name":  "Elle",
+			"count": 7.0,
+			"label": "none",
+		}))
+	})
+
+	It("should fail on options never registered", func() {
+		var cfg MyEffectiveConfig
+		_, err := config.Effective(&cfg)
+		Expect(err).To(Equal(config.ErrNotRegistered))
+	})
+})
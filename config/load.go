@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// structOptions adapts a plain struct to Options.
+type structOptions[T any] struct {
+	Options
+
+	Value T
+}
+
+// Load loads options of type T from the arguments, environment variables, the config file specified
+// by "-yaml" and sources, in order of precedence.
+//
+// T is a plain struct with tagged fields and needs not embed Options. Structs embedding Options, e.g.
+// LoggerOptions, are supported as fields of T. Nil pointers to structs are allocated. T and its nested
+// structs are validated if they implement Validate() error. Structural problems of T, e.g. recursive
+// pointers, are reported as ErrInvalidOptions.
+//
+// If returns ErrPrintUsage, the usage has been printed to stderr.
+func Load[T any](args []string, sources ...Source) (*T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %v is not a struct", ErrInvalidOptions, t)
+	}
+
+	name := t.Name()
+	if len(os.Args) > 0 {
+		name = os.Args[0]
+	}
+	opts := &structOptions[T]{}
+	loader := NewLoader(name, sources...)
+	if err := loader.Load(opts, args...); err == ErrPrintUsage {
+		PrintUsage(opts)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+	return &opts.Value, nil
+}
@@ -0,0 +1,117 @@
+package config_test
+
+import (
+	"errors"
+
+	"github.com/Scusemua/go-utils/config"
+	"github.com/Scusemua/go-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MyMode string
+
+type MyPlainConfig struct {
+	Name  string `name:"name" default:"Tianium" description:"Option \"name\"."`
+	Count int    `name:"count" description:"Option \"count\"."`
+	Mode  MyMode `name:"mode" default:"fast" description:"Option \"mode\"."`
+
+	Logger *config.LoggerOptions
+	Nested MyExtensionConfig
+
+	private *int
+}
+
+func (c *MyPlainConfig) Validate() error {
+	if c.Count < 0 {
+		return errors.New("negative count")
+	}
+	return nil
+}
+
+type MyDuplicateConfig struct {
+	Name  string `name:"name" description:"Option \"name\"."`
+	Alias string `name:"name" description:"Option \"name\" again."`
+}
+
+type MyPointerConfig struct {
+	Nested *MyExtensionConfig
+	Plain  *MyDefaultPlainConfig
+}
+
+type MyDefaultPlainConfig struct {
+	Count int `name:"plain-count" default:"3" description:"Option \"plain-count\"."`
+}
+
+type MyRecursiveConfig struct {
+	Name string `name:"name" description:"Option \"name\"."`
+	Next *MyRecursiveConfig
+}
+
+type MyUnsupportedConfig struct {
+	Names []string `name:"names" description:"Option \"names\"."`
+}
+
+var _ = Describe("Load", func() {
+	AfterEach(func() {
+		config.LogLevel = config.DefaultLogLevel
+	})
+
+	It("should load plain structs", func() {
+		cfg, err := config.Load[MyPlainConfig]([]string{"-count=2", "-mode=slow", "-debug", "-extension=test"})
+		Expect(err).To(BeNil())
+		Expect(cfg.Name).To(Equal("Tianium"))
+		Expect(cfg.Count).To(Equal(2))
+		Expect(cfg.Mode).To(Equal(MyMode("slow")))
+		Expect(cfg.Logger).NotTo(BeNil())
+		Expect(cfg.Logger.Debug).To(Equal(true))
+		Expect(cfg.Nested.Extension).To(Equal("test"))
+		Expect(config.LogLevel).To(Equal(logger.LOG_LEVEL_ALL))
+	})
+
+	It("should load plain structs from sources", func() {
+		cfg, err := config.Load[MyPlainConfig]([]string{"-yaml=options_test.yml"},
+			config.NewMapSource("remote", map[string]interface{}{"count": 5, "name": "Remote"}))
+		Expect(err).To(BeNil())
+		Expect(cfg.Name).To(Equal("Tianium"))
+		Expect(cfg.Count).To(Equal(5))
+		Expect(cfg.Logger.Debug).To(Equal(true))
+	})
+
+	It("should honor Validate", func() {
+		_, err := config.Load[MyPlainConfig]([]string{"-count=-1"})
+		Expect(err).To(MatchError("negative count"))
+	})
+
+	It("should load pointers to plain structs", func() {
+		cfg, err := config.Load[MyPointerConfig]([]string{"-extension=x"})
+		Expect(err).To(BeNil())
+		Expect(cfg.Nested).NotTo(BeNil())
+		Expect(cfg.Nested.Extension).To(Equal("x"))
+		Expect(cfg.Plain).NotTo(BeNil())
+		Expect(cfg.Plain.Count).To(Equal(3))
+	})
+
+	It("should load structs embedding options", func() {
+		cfg, err := config.Load[MyConfig]([]string{"-name=Elle"})
+		Expect(err).To(BeNil())
+		Expect(cfg.Name).To(Equal("Elle"))
+	})
+
+	It("should report structural problems", func() {
+		_, err := config.Load[int](nil)
+		Expect(errors.Is(err, config.ErrInvalidOptions)).To(Equal(true))
+
+		_, err = config.Load[MyDuplicateConfig](nil)
+		Expect(errors.Is(err, config.ErrInvalidOptions)).To(Equal(true))
+		Expect(err.Error()).To(ContainSubstring("MyDuplicateConfig.Alias"))
+
+		_, err = config.Load[MyUnsupportedConfig](nil)
+		Expect(errors.Is(err, config.ErrInvalidOptions)).To(Equal(true))
+		Expect(err.Error()).To(ContainSubstring("MyUnsupportedConfig.Names"))
+
+		_, err = config.Load[MyRecursiveConfig](nil)
+		Expect(errors.Is(err, config.ErrInvalidOptions)).To(Equal(true))
+		Expect(err.Error()).To(ContainSubstring("MyRecursiveConfig is recursive"))
+	})
+})
@@ -0,0 +1,290 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	ErrNotRegistered = errors.New("options are not registered")
+)
+
+// validator is implemented by options and plain structs that validate themselves.
+type validator interface {
+	Validate() error
+}
+
+// Loader loads options from command line arguments, environment variables, the config file
+// specified by "-yaml" and additional Sources. Former ones take precedence.
+//
+// The config file can be overlaid by a profile selected by "-profile" or $CONFIG_PROFILE,
+// see NewProfileFileSource.
+//
+// A Loader owns the FlagSet it registers options to. Loaders working on different options
+// are independent and can be used concurrently, except that LoggerOptions set logger settings of
+// the package, e.g. LogLevel, which are shared by all Loaders.
+type Loader struct {
+	// Sources supply values of options that are set by neither flags, environment variables
+	// nor the config file. Former sources take precedence.
+	Sources []Source
+
+	// Strict validates the config file against the schema of the options before merging it,
+	// and fails on unknown options or invalid values. See ValidateFile.
+	Strict bool
+
+	flags      *flag.FlagSet
+	help       bool
+	completion string
+	owned      bool
+	opts       Options
+	base       reflect.Value
+
+	// flagged records values of options set by flags for reloading.
+	flagged map[string]string
+
+	// computed records values of options changed by validation, e.g. a generated seed, for reloading.
+	computed map[string]string
+}
+
+// NewLoader creates a Loader with a FlagSet of its own. The FlagSet defines a "-h" flag,
+// on which Parse returns ErrPrintUsage.
+func NewLoader(name string, sources ...Source) *Loader {
+	loader := newLoader(flag.NewFlagSet(name, flag.ContinueOnError), true)
+	loader.Sources = sources
+	return loader
+}
+
+// NewLoaderWithFlagSet creates a Loader that registers options to the FlagSet of the host program.
+// The host program parses the FlagSet and calls Resolve afterward.
+//
+// To use with pflag, register options to a flag.FlagSet and add it by pflag.FlagSet.AddGoFlagSet.
+func NewLoaderWithFlagSet(flags *flag.FlagSet, sources ...Source) *Loader {
+	loader := newLoader(flags, false)
+	loader.Sources = sources
+	return loader
+}
+
+// copySettings copies the settings of the other Loader, i.e. its exported fields, e.g. for reloads.
+// Settings added to Loader must be copied here.
+func (l *Loader) copySettings(other *Loader) {
+	l.Sources = other.Sources
+	l.Strict = other.Strict
+}
+
+func newLoader(flags *flag.FlagSet, owned bool) *Loader {
+	loader := &Loader{flags: flags, owned: owned}
+	if owned {
+		flags.BoolVar(&loader.help, "h", false, "Show help.")
+	}
+	return loader
+}
+
+// FlagSet returns the FlagSet options are registered to.
+func (l *Loader) FlagSet() *flag.FlagSet {
+	return l.flags
+}
+
+// Load registers the options, parses the arguments and resolves the options.
+// If returns ErrPrintUsage, the usage should be printed.
+func (l *Loader) Load(opts Options, args ...string) error {
+	if err := l.Register(opts); err != nil {
+		return err
+	}
+	return l.Parse(args...)
+}
+
+// Register registers the options to the FlagSet based on defined tags.
+// Structural problems of the options, e.g. unsupported field types, are reported as ErrInvalidOptions.
+func (l *Loader) Register(opts Options) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %T: %v", ErrInvalidOptions, opts, r)
+		}
+	}()
+
+	if err := Polyfill(opts, nil); err != nil {
+		return err
+	}
+
+	opts.meta().reset(l.flags)
+	if err := opts.init(opts); err != nil {
+		return err
+	}
+
+	l.opts = opts
+	l.base = cloneOptions(reflect.ValueOf(opts).Elem())
+	if l.owned {
+		l.flags.Usage = func() { PrintUsage(opts) }
+	}
+	return nil
+}
+
+// Parse parses the arguments and resolves the registered options.
+// If returns ErrPrintUsage, the usage should be printed.
+func (l *Loader) Parse(args ...string) error {
+	if err := l.flags.Parse(args); err != nil {
+		return err
+	} else if l.help {
+		return ErrPrintUsage
+	} else if l.completion != "" {
+		return ErrPrintCompletion
+	}
+	return l.Resolve()
+}
+
+// Resolve fills the registered options not set by flags from environment variables, the config file
+// and Sources, then validates the options.
+func (l *Loader) Resolve() error {
+	if l.opts == nil {
+		return ErrNotRegistered
+	}
+
+	meta := l.opts.meta()
+	aliases := meta.aliases()
+	set := make(map[string]bool)
+	l.flagged = make(map[string]string)
+	l.flags.Visit(func(f *flag.Flag) {
+		name := f.Name
+		if field, ok := aliases[name]; ok {
+			warnDeprecated(field, "-"+name, "-"+field.name)
+			name = field.name
+		}
+		set[name] = true
+		l.flagged[name] = f.Value.String()
+	})
+	for _, field := range meta.fields {
+		field.origin, field.source = OriginDefault, ""
+		if set[field.name] {
+			field.origin = OriginFlag
+		}
+	}
+
+	for _, field := range meta.fields {
+		if set[field.name] {
+			continue
+		}
+		env, val, ok := lookupEnv(field)
+		if !ok {
+			continue
+		}
+		if err := l.flags.Set(field.name, val); err != nil {
+			return fmt.Errorf("invalid value \"%s\" for \"%s\" from $%s: %v", val, field.name, env, err)
+		}
+		set[field.name] = true
+		field.origin, field.source = OriginEnv, env
+	}
+
+	if meta.YAML != "" {
+		if err := l.merge(meta, NewProfileFileSource(meta.YAML, meta.Profile), OriginFile, set); err != nil {
+			return err
+		}
+	}
+	for _, source := range l.Sources {
+		if err := l.merge(meta, source, OriginSource, set); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range meta.fields {
+		if field.deprecated != "" && !field.aliased() && field.origin != OriginDefault {
+			warnDeprecated(field, "-"+field.name, "")
+		}
+	}
+
+	for _, field := range meta.fields {
+		if err := checkOneOf(field, l.flags.Lookup(field.name).Value.String()); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range meta.fields {
+		if field.secret {
+			if err := resolveSecret(field); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Validate the root first, other options may rely on merged values.
+	if err := meta.Validate(); err != nil {
+		return err
+	}
+	for _, t := range meta.order {
+		if t == reflect.TypeOf(meta) {
+			continue
+		}
+		if v, ok := meta.seen[t].(validator); ok {
+			if err := v.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	l.computed = make(map[string]string)
+	for _, field := range meta.fields {
+		if field.origin == OriginDefault && !field.secret && fmt.Sprintf("%v", field.value.Interface()) != field.defValue {
+			l.computed[field.name] = l.flags.Lookup(field.name).Value.String()
+		}
+	}
+	return nil
+}
+
+func (l *Loader) merge(meta *options, source Source, origin Origin, set map[string]bool) error {
+	data, err := source.Load()
+	if err != nil {
+		return err
+	}
+	if l.Strict && origin == OriginFile {
+		s, err := schemaOf(l.opts)
+		if err != nil {
+			return err
+		} else if err := s.validate(source.Name(), data); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range meta.fields {
+		if set[field.name] {
+			continue
+		}
+		v, ok := data[field.name]
+		for _, alias := range field.aliases {
+			if ok {
+				break
+			}
+			if v, ok = data[alias]; ok {
+				warnDeprecated(field, fmt.Sprintf("\"%s\" in %s", alias, source.Name()), field.name)
+			}
+		}
+		if !ok || v == nil {
+			// A null value leaves the option to sources of lower precedence.
+			continue
+		}
+		if kind := reflect.ValueOf(v).Kind(); kind == reflect.Map || kind == reflect.Slice || kind == reflect.Array {
+			return fmt.Errorf("invalid value for \"%s\" from %s: %s is not supported", field.name, source.Name(), kind)
+		}
+
+		if err := l.flags.Set(field.name, fmt.Sprintf("%v", v)); err != nil {
+			return fmt.Errorf("invalid value \"%v\" for \"%s\" from %s: %v", v, field.name, source.Name(), err)
+		}
+		set[field.name] = true
+		field.origin, field.source = origin, source.Name()
+	}
+	return nil
+}
+
+// checkOneOf checks the value of the option set from anywhere other than the default is allowed.
+func checkOneOf(field *optionField, val string) error {
+	if len(field.oneof) == 0 || field.origin == OriginDefault {
+		return nil
+	}
+	for _, allowed := range field.oneof {
+		if val == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value \"%s\" for \"%s\": must be one of %s", val, field.name, strings.Join(field.oneof, ", "))
+}
@@ -0,0 +1,134 @@
+package config_test
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+
+	"github.com/Scusemua/go-utils/config"
+	"github.com/Scusemua/go-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Loader", func() {
+	AfterEach(func() {
+		config.LogLevel = config.DefaultLogLevel
+	})
+
+	It("should load options with a FlagSet of its own", func() {
+		var cfg MyConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg, "-test", "-name=Elle")).To(Succeed())
+		Expect(cfg.Test).To(Equal(true))
+		Expect(cfg.Name).To(Equal("Elle"))
+		Expect(loader.FlagSet().Lookup("name")).NotTo(BeNil())
+	})
+
+	It("should return ErrPrintUsage on help", func() {
+		var cfg MyConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-h")).To(Equal(config.ErrPrintUsage))
+	})
+
+	It("should be safe for concurrent independent use", func() {
+		var wg sync.WaitGroup
+		cfgs := make([]MyConfig, 10)
+		errs := make([]error, len(cfgs))
+		for i := range cfgs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = config.NewLoader("test").Load(&cfgs[i], fmt.Sprintf("-name=%d", i))
+			}(i)
+		}
+		wg.Wait()
+
+		for i := range cfgs {
+			Expect(errs[i]).To(BeNil())
+			Expect(cfgs[i].Name).To(Equal(fmt.Sprintf("%d", i)))
+		}
+	})
+
+	It("should be safe for concurrent use with logger and seed options", func() {
+		var wg sync.WaitGroup
+		loggerCfgs := make([]MyLoggerConfig, 50)
+		compositeCfgs := make([]MyCompositeConfig, len(loggerCfgs))
+		errs := make([]error, len(loggerCfgs)*2)
+		for i := range loggerCfgs {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = config.NewLoader("test").Load(&loggerCfgs[i], "-debug", "-test")
+				config.GetLogger(config.LogConfig).Debug("Loaded %d", i)
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				errs[len(loggerCfgs)+i] = config.NewLoader("test").Load(&compositeCfgs[i], "-debug", fmt.Sprintf("-seed=%d", i+1))
+				config.GetDefaultLogger().Debug("Loaded %d", i)
+			}(i)
+		}
+		wg.Wait()
+
+		for i := range loggerCfgs {
+			Expect(errs[i]).To(BeNil())
+			Expect(errs[len(loggerCfgs)+i]).To(BeNil())
+			Expect(loggerCfgs[i].Debug).To(BeTrue())
+			Expect(compositeCfgs[i].Seed).To(Equal(int64(i + 1)))
+		}
+		Expect(config.LogLevel).To(Equal(logger.LOG_LEVEL_ALL))
+	})
+
+	It("should register options to an external FlagSet", func() {
+		var host bool
+		flags := flag.NewFlagSet("host", flag.ContinueOnError)
+		flags.BoolVar(&host, "host", false, "Host flag.")
+
+		var cfg MyConfig
+		loader := config.NewLoaderWithFlagSet(flags)
+		Expect(loader.Register(&cfg)).To(Succeed())
+		Expect(flags.Lookup("h")).To(BeNil())
+
+		Expect(flags.Parse([]string{"-host", "-yaml=options_test.yml", "-name=Elle"})).To(Succeed())
+		Expect(loader.Resolve()).To(Succeed())
+		Expect(host).To(Equal(true))
+		Expect(cfg.Test).To(Equal(true))
+		Expect(cfg.Name).To(Equal("Elle"))
+	})
+
+	It("should fail to resolve unregistered options", func() {
+		Expect(config.NewLoader("test").Resolve()).To(Equal(config.ErrNotRegistered))
+	})
+
+	It("should apply sources in order of precedence", func() {
+		var cfg MyConfig
+		loader := config.NewLoader("test",
+			config.NewMapSource("first", map[string]interface{}{"name": "First"}),
+			config.NewMapSource("second", map[string]interface{}{"name": "Second", "test": true}),
+		)
+		Expect(loader.Load(&cfg)).To(Succeed())
+		Expect(cfg.Name).To(Equal("First"))
+		Expect(cfg.Test).To(Equal(true))
+	})
+
+	It("should config file take precedence over sources", func() {
+		var cfg MyConfig
+		loader := config.NewLoader("test", config.NewMapSource("remote", map[string]interface{}{"name": "Remote"}))
+		Expect(loader.Load(&cfg, "-yaml=options_test.yml")).To(Succeed())
+		Expect(cfg.Name).To(Equal("Tianium"))
+	})
+
+	It("should report the source of invalid values", func() {
+		var cfg MyDefaultConfig
+		loader := config.NewLoader("test", config.NewMapSource("remote", map[string]interface{}{"count": "many"}))
+		err := loader.Load(&cfg)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("from remote"))
+	})
+
+	It("should load the same options again", func() {
+		var cfg MyConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-name=Elle")).To(Succeed())
+		Expect(config.NewLoader("test").Load(&cfg, "-name=Tianium")).To(Succeed())
+		Expect(cfg.Name).To(Equal("Tianium"))
+	})
+})
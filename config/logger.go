@@ -2,12 +2,14 @@ package config
 
 import (
 	"reflect"
+	"sync"
 
 	"github.com/Scusemua/go-utils/logger"
 )
 
 const (
 	LogDefault = "default"
+	LogConfig  = "Config "
 )
 
 var (
@@ -38,6 +40,10 @@ var (
 	// a given log message (e.g., "ERROR", "WARNING", "DEBUG", "TRACE", or "INFO") to the beginning of
 	// the message when emitting it.
 	LogTypePrefix = true
+
+	// logMu guards LogLevel and Verbose written by LoggerOptions against loggers being created.
+	// Writing the settings directly is not synchronized, and should happen before loading options.
+	logMu sync.RWMutex
 )
 
 type LoggerOptions struct {
@@ -48,6 +54,9 @@ type LoggerOptions struct {
 }
 
 func (o *LoggerOptions) Validate() error {
+	logMu.Lock()
+	defer logMu.Unlock()
+
 	if o.Debug {
 		LogLevel = logger.LOG_LEVEL_ALL
 	} else {
@@ -64,6 +73,9 @@ func GetDefaultLogger() logger.Logger {
 }
 
 func GetLogger(prefix string) logger.Logger {
+	logMu.RLock()
+	defer logMu.RUnlock()
+
 	return &logger.ColorLogger{
 		Prefix:        prefix,
 		Color:         LogColor,
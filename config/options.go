@@ -6,23 +6,44 @@ import (
 	"fmt"
 	"os"
 	"reflect"
-
-	configKit "github.com/gookit/config/v2"
-	"github.com/gookit/config/v2/yaml"
-	"github.com/mitchellh/mapstructure"
+	"strconv"
+	"strings"
 )
 
 const (
-	OptionName = "name"
-	OptionDesc = "description"
+	OptionName    = "name"
+	OptionDesc    = "description"
+	OptionDefault = "default"
+	OptionEnv     = "env"
+	OptionSecret  = "secret"
+
+	// OptionAlias lists former names of an option separated by commas, which are still accepted from flags
+	// and config files. Names prefixed by "$" are former environment variables.
+	OptionAlias = "alias"
+
+	// OptionDeprecated is the hint logged when an alias is used, e.g. "use -new-name".
+	// An option without aliases is deprecated itself and the hint is logged whenever it is set.
+	OptionDeprecated = "deprecated"
+
+	// OptionOneOf lists allowed values of an option separated by commas, which are also completed by shells.
+	OptionOneOf = "oneof"
+
+	// OptionComplete hints shells to complete values of an option by paths: "file" or "dir".
+	OptionComplete = "complete"
 )
 
 var (
-	Flag          *flag.FlagSet = flag.NewFlagSet("", flag.ContinueOnError)
-	ErrNonPointer               = errors.New("validate with non-pointer")
-	ErrPrintUsage               = errors.New("print usage")
-
-	zeroValue = reflect.Value{}
+	// Flag is the FlagSet used by ValidateOptions and ValidateOptionsWithFlags.
+	//
+	// Deprecated: Flag is replaced whenever options are validated again. Use Loader.FlagSet instead.
+	Flag               *flag.FlagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	ErrNonPointer                    = errors.New("validate with non-pointer")
+	ErrPrintUsage                    = errors.New("print usage")
+	ErrInvalidOptions                = errors.New("invalid options")
+	ErrPrintCompletion               = errors.New("print completion")
+
+	zeroValue   = reflect.Value{}
+	optionsType = reflect.TypeOf((*Options)(nil)).Elem()
 )
 
 // Options is the interface for all config options.
@@ -39,11 +60,39 @@ type Options interface {
 }
 
 type options struct {
-	YAML string `name:"yaml" description:"Path to config file in the yml format."`
+	YAML    string `name:"yaml" complete:"file" description:"Path to config file in the yml format."`
+	Profile string `name:"profile" env:"CONFIG_PROFILE" description:"Profile to overlay the config file, e.g. prod."`
+
+	root   Options
+	flags  *flag.FlagSet
+	seen   map[reflect.Type]interface{}
+	order  []reflect.Type
+	fields []*optionField
+	raw    reflect.Value
+
+	// path lists types being initialized to detect recursive pointers.
+	path []reflect.Type
+}
 
-	root Options
-	seen map[reflect.Type]interface{}
-	raw  reflect.Value
+// optionField records an option registered from a tagged struct field.
+type optionField struct {
+	name     string
+	desc     string
+	env      string
+	group    string
+	kind     reflect.Kind
+	defValue string
+	value    reflect.Value
+	origin   Origin
+	source   string
+	secret   bool
+	ref      string
+
+	aliases    []string
+	envAliases []string
+	deprecated string
+	oneof      []string
+	complete   string
 }
 
 func NewOptions() Options {
@@ -52,6 +101,15 @@ func NewOptions() Options {
 	return root
 }
 
+// reset clears the registered options so that they can be registered to flags again.
+func (o *options) reset(flags *flag.FlagSet) {
+	o.flags = flags
+	o.seen = make(map[reflect.Type]interface{})
+	o.order = nil
+	o.fields = nil
+	o.raw = zeroValue
+}
+
 func Polyfill(opts Options, fill Options) error {
 	val := reflect.ValueOf(opts)
 	if val.Kind() != reflect.Ptr {
@@ -74,9 +132,37 @@ func Polyfill(opts Options, fill Options) error {
 	return nil
 }
 
+// cloneOptions returns a copy of the options struct that is detached from the meta info of the original,
+// so that the copy can be registered and validated independently.
+func cloneOptions(v reflect.Value) reflect.Value {
+	clone := reflect.New(v.Type()).Elem()
+	clone.Set(v)
+	detachOptions(clone)
+	return clone
+}
+
+func detachOptions(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		switch {
+		case field.Type() == optionsType:
+			field.Set(reflect.Zero(optionsType))
+		case field.Kind() == reflect.Struct:
+			detachOptions(field)
+		case field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct && !field.IsNil():
+			field.Set(cloneOptions(field.Elem()).Addr())
+		}
+	}
+}
+
 // ValidateOptions validates the options with command line arguments.
 // Returns a FlagSet and error.
 // If returns ErrPrintUsage, the usage should be printed.
+// If returns ErrPrintCompletion, the completion script requested by "-completion=<shell>" has been printed to stdout.
 func ValidateOptions(opts Options) (*flag.FlagSet, error) {
 	return ValidateOptionsWithFlags(opts, os.Args[1:]...)
 }
@@ -84,44 +170,59 @@ func ValidateOptions(opts Options) (*flag.FlagSet, error) {
 // ValidateOptionsWithFlags validates the options with specified arguments.
 // Returns a FlagSet and error.
 // If returns ErrPrintUsage, the usage should be printed.
+//
+// ValidateOptionsWithFlags registers options to the package-level Flag and is not safe for concurrent use.
+// Use a Loader to parse options concurrently or into a FlagSet of your own.
 func ValidateOptionsWithFlags(opts Options, args ...string) (*flag.FlagSet, error) {
-	var printInfo bool
+	return ValidateOptionsWithSources(opts, nil, args...)
+}
+
+// ValidateOptionsWithSources validates the options with specified arguments, layered over sources,
+// e.g. NewProviderSource of a remote config service.
+// Returns a FlagSet and error.
+// If returns ErrPrintUsage, the usage should be printed.
+// If returns ErrPrintCompletion, the completion script requested by "-completion=<shell>" has been printed to stdout.
+//
+// ValidateOptionsWithSources registers options to the package-level Flag and is not safe for concurrent use.
+// Use a Loader to parse options concurrently or into a FlagSet of your own.
+func ValidateOptionsWithSources(opts Options, sources []Source, args ...string) (*flag.FlagSet, error) {
 	if Flag.Parsed() {
 		Flag = flag.NewFlagSet(Flag.Name(), Flag.ErrorHandling())
 	}
-	Flag.BoolVar(&printInfo, "h", false, "Show help.")
-
-	if err := Polyfill(opts, nil); err != nil {
-		return Flag, err
-	}
-	if err := opts.init(opts); err != nil {
+	loader := newLoader(Flag, true)
+	loader.Sources = sources
+	if err := loader.Register(opts); err != nil {
 		return Flag, err
 	}
-
-	err := Flag.Parse(args)
-	if err == nil && printInfo {
-		err = ErrPrintUsage
-	}
-	if err != nil {
-		return Flag, err
+	if Flag.Lookup("completion") == nil {
+		Flag.StringVar(&loader.completion, "completion", "", "Print the completion script of the shell: bash, zsh or fish.")
 	}
 
-	meta := opts.meta()
-	for _, s := range meta.seen {
-		if opts, ok := s.(Options); ok {
-			if err := opts.Validate(); err != nil {
-				return Flag, err
-			}
+	err := loader.Parse(args...)
+	if err == ErrPrintCompletion {
+		if err := WriteCompletion(os.Stdout, opts, loader.completion); err != nil {
+			return Flag, err
 		}
 	}
-
-	return Flag, nil
+	return Flag, err
 }
 
 func (o *options) init(opts interface{}) error {
 	t := reflect.TypeOf(opts)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %v is not a pointer to struct", ErrInvalidOptions, t)
+	} else if reflect.ValueOf(opts).IsNil() {
+		return fmt.Errorf("%w: nil %v", ErrInvalidOptions, t)
+	}
+	for _, p := range o.path {
+		if p == t {
+			return fmt.Errorf("%w: %v is recursive", ErrInvalidOptions, t.Elem())
+		}
+	}
+	o.path = append(o.path, t)
 	defer func() {
-		o.seen[t] = opts
+		o.path = o.path[:len(o.path)-1]
+		o.markSeen(t, opts)
 		// log.Printf("seen %v", t)
 	}()
 
@@ -139,7 +240,15 @@ func (o *options) init(opts interface{}) error {
 
 		// Recursively check embedded options except the "root".
 		opt := oVal.Field(i)
-		if opt.Kind() != reflect.Interface && opt.Kind() != reflect.Ptr {
+		if opt.Kind() == reflect.Ptr && opt.IsNil() && field.Type.Implements(optionsType) {
+			if field.Type.Elem().Kind() != reflect.Struct {
+				return fmt.Errorf("%w: %s.%s is not a pointer to struct", ErrInvalidOptions, oType.Name(), field.Name)
+			}
+			opt.Set(reflect.New(field.Type.Elem()))
+		} else if opt.Kind() == reflect.Ptr && opt.IsNil() && field.Type.Elem().Kind() == reflect.Struct {
+			// Pointers to plain structs are allocated and checked like nested structs.
+			opt.Set(reflect.New(field.Type.Elem()))
+		} else if opt.Kind() != reflect.Interface && opt.Kind() != reflect.Ptr {
 			opt = opt.Addr()
 		}
 		if opt.CanInterface() {
@@ -157,12 +266,9 @@ func (o *options) init(opts interface{}) error {
 					return err
 				}
 				// Make sure the Options interface is seen too.
-				if _, seen := o.seen[opt.Type()]; !seen {
-					o.seen[opt.Type()] = innerOpts
-					// log.Printf("seen %v", opt.Type())
-				}
+				o.markSeen(opt.Type(), innerOpts)
 				continue
-			} else if field.Type.Kind() == reflect.Struct {
+			} else if field.Type.Kind() == reflect.Struct || field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
 				if err := o.init(opt.Interface()); err != nil {
 					return err
 				}
@@ -174,25 +280,59 @@ func (o *options) init(opts interface{}) error {
 		name := field.Tag.Get(OptionName)
 		if name == "" {
 			continue
+		} else if o.flags.Lookup(name) != nil {
+			return fmt.Errorf("%w: option \"%s\" of %s.%s is defined already", ErrInvalidOptions, name, oType.Name(), field.Name)
+		}
+		if def, ok := field.Tag.Lookup(OptionDefault); ok && opt.Elem().IsZero() {
+			if err := setValue(opt.Elem(), def); err != nil {
+				return fmt.Errorf("invalid default value \"%s\" for \"%s\": %v", def, name, err)
+			}
+		}
+		secret := field.Tag.Get(OptionSecret) == "true"
+		if secret && field.Type.Kind() != reflect.String {
+			return fmt.Errorf("%w: unsupprted secret type: %v(%s.%s)", ErrInvalidOptions, field.Type.Kind(), oType.Name(), field.Name)
 		}
 		desc := field.Tag.Get(OptionDesc)
 		switch field.Type.Kind() {
 		case reflect.Bool:
-			Flag.BoolVar(opt.Interface().(*bool), name, opt.Elem().Bool(), desc)
+			o.flags.BoolVar(fieldPtr[bool](opt), name, opt.Elem().Bool(), desc)
 		case reflect.Int:
-			Flag.IntVar(opt.Interface().(*int), name, int(opt.Elem().Int()), desc)
+			o.flags.IntVar(fieldPtr[int](opt), name, int(opt.Elem().Int()), desc)
 		case reflect.Int64:
-			Flag.Int64Var(opt.Interface().(*int64), name, opt.Elem().Int(), desc)
+			o.flags.Int64Var(fieldPtr[int64](opt), name, opt.Elem().Int(), desc)
 		case reflect.Uint:
-			Flag.UintVar(opt.Interface().(*uint), name, uint(opt.Elem().Uint()), desc)
+			o.flags.UintVar(fieldPtr[uint](opt), name, uint(opt.Elem().Uint()), desc)
 		case reflect.Uint64:
-			Flag.Uint64Var(opt.Interface().(*uint64), name, opt.Elem().Uint(), desc)
+			o.flags.Uint64Var(fieldPtr[uint64](opt), name, opt.Elem().Uint(), desc)
 		case reflect.Float64:
-			Flag.Float64Var(opt.Interface().(*float64), name, opt.Elem().Float(), desc)
+			o.flags.Float64Var(fieldPtr[float64](opt), name, opt.Elem().Float(), desc)
 		case reflect.String:
-			Flag.StringVar(opt.Interface().(*string), name, opt.Elem().String(), desc)
+			o.flags.StringVar(fieldPtr[string](opt), name, opt.Elem().String(), desc)
 		default:
-			return fmt.Errorf("unsupprted config type: %v(%s)", field.Type.Kind(), field.Name)
+			return fmt.Errorf("%w: unsupprted config type: %v(%s.%s)", ErrInvalidOptions, field.Type.Kind(), oType.Name(), field.Name)
+		}
+		flag := o.flags.Lookup(name)
+		o.fields = append(o.fields, &optionField{
+			name:     name,
+			desc:     desc,
+			env:      field.Tag.Get(OptionEnv),
+			group:    groupName(oType),
+			kind:     field.Type.Kind(),
+			defValue: flag.DefValue,
+			value:    opt.Elem(),
+			origin:   OriginDefault,
+			secret:   secret,
+		})
+		if secret {
+			// Keep secrets out of flag.PrintDefaults.
+			flag.DefValue = ""
+		}
+		if oneof := field.Tag.Get(OptionOneOf); oneof != "" {
+			o.fields[len(o.fields)-1].oneof = strings.Split(oneof, ",")
+		}
+		o.fields[len(o.fields)-1].complete = field.Tag.Get(OptionComplete)
+		if err := o.registerAliases(o.fields[len(o.fields)-1], flag, field.Tag); err != nil {
+			return fmt.Errorf("%w: %v of %s.%s", ErrInvalidOptions, err, oType.Name(), field.Name)
 		}
 	}
 
@@ -203,45 +343,63 @@ func (o *options) meta() *options {
 	return o
 }
 
-func (o *options) Validate() error {
-	if o.YAML != "" {
-		yml := o.YAML
-		o.YAML = ""
-
-		config := configKit.NewWithOptions("", func(opt *configKit.Options) {
-			opt.TagName = OptionName
-			// DecoderConfig initialization is due a bug in configKit: no TagName will be applied if DecoderConfig is nil.
-			// TODO: Fix the bug
-			opt.DecoderConfig = &mapstructure.DecoderConfig{}
-		})
-		config.AddDriver(yaml.Driver)
-
-		if err := config.LoadFiles(yml); err != nil {
-			return err
-		}
+func (o *options) markSeen(t reflect.Type, opts interface{}) {
+	if _, seen := o.seen[t]; seen {
+		return
+	}
+	o.seen[t] = opts
+	o.order = append(o.order, t)
+}
 
-		// fileOpts := reflect.New(o.raw.Type())
-		// if err := config.BindStruct("", fileOpts.Interface()); err != nil {
-		// 	return err
-		// }
+// fieldPtr converts the pointer to a field of named type to the pointer of the underlying type.
+func fieldPtr[V any](opt reflect.Value) *V {
+	return opt.Convert(reflect.TypeOf((*V)(nil))).Interface().(*V)
+}
 
-		// Merge the options by flags set.
-		data := config.Data()
-		for k, v := range data {
-			flag := Flag.Lookup(k)
-			if flag == nil || flag.Value.String() != flag.DefValue {
-				continue
-			}
+func groupName(t reflect.Type) string {
+	if t == reflect.TypeOf(options{}) {
+		return "Options"
+	}
+	return t.Name()
+}
 
-			err := flag.Value.Set(fmt.Sprintf("%v", v))
-			if err != nil {
-				return fmt.Errorf("invalid value \"%s\" for \"%s\": %v", v, flag.Name, err)
-			}
+// setValue parses str and sets it to v according to the kind of v.
+func setValue(v reflect.Value, str string) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(str, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint64:
+		u, err := strconv.ParseUint(str, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return err
 		}
-		// if err := mergo.Merge(o.raw.Addr().Interface(), fileOpts.Interface()); err != nil {
-		// 	return err
-		// }
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(str)
+	default:
+		return fmt.Errorf("unsupprted config type: %v", v.Kind())
 	}
+	return nil
+}
 
+// Validate does nothing. The config file specified by YAML is merged by the Loader before
+// options are validated.
+func (o *options) Validate() error {
 	return nil
 }
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"flag"
+	"os"
 
 	"github.com/Scusemua/go-utils/config"
 	"github.com/Scusemua/go-utils/logger"
@@ -31,6 +32,13 @@ type MyCompositeConfig struct {
 	Logger config.LoggerOptions
 }
 
+type MyDefaultConfig struct {
+	config.Options
+
+	Name  string `name:"name" default:"Tianium" env:"MY_DEFAULT_NAME" description:"Option \"name\"."`
+	Count int    `name:"count" default:"3" env:"MY_DEFAULT_COUNT" description:"Option \"count\"."`
+}
+
 type MyCompositeExtension struct {
 	config.SeedOptions
 	MyExtensionConfig
@@ -156,4 +164,50 @@ var _ = Describe("Options", func() {
 		Expect(cfg.Test).To(Equal(true))
 		Expect(cfg.Name).To(Equal("Elle"))
 	})
+
+	It("should default tag fill unset options", func() {
+		var cfg MyDefaultConfig
+		flagSet, err := config.ValidateOptionsWithFlags(&cfg, "-count=5")
+		checkFlagSet(flagSet, err)
+
+		Expect(err).To(BeNil())
+		Expect(cfg.Name).To(Equal("Tianium"))
+		Expect(cfg.Count).To(Equal(5))
+		Expect(flagSet.Lookup("name").DefValue).To(Equal("Tianium"))
+	})
+
+	It("should default tag not override values set in code", func() {
+		cfg := MyDefaultConfig{Name: "Elle"}
+		flagSet, err := config.ValidateOptionsWithFlags(&cfg)
+		checkFlagSet(flagSet, err)
+
+		Expect(err).To(BeNil())
+		Expect(cfg.Name).To(Equal("Elle"))
+		Expect(cfg.Count).To(Equal(3))
+	})
+
+	It("should environment variables override defaults but not flags", func() {
+		os.Setenv("MY_DEFAULT_NAME", "Env")
+		os.Setenv("MY_DEFAULT_COUNT", "7")
+		defer os.Unsetenv("MY_DEFAULT_NAME")
+		defer os.Unsetenv("MY_DEFAULT_COUNT")
+
+		var cfg MyDefaultConfig
+		flagSet, err := config.ValidateOptionsWithFlags(&cfg, "-count=5")
+		checkFlagSet(flagSet, err)
+
+		Expect(err).To(BeNil())
+		Expect(cfg.Name).To(Equal("Env"))
+		Expect(cfg.Count).To(Equal(5))
+	})
+
+	It("should invalid environment variable fail validation", func() {
+		os.Setenv("MY_DEFAULT_COUNT", "many")
+		defer os.Unsetenv("MY_DEFAULT_COUNT")
+
+		var cfg MyDefaultConfig
+		_, err := config.ValidateOptionsWithFlags(&cfg)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("MY_DEFAULT_COUNT"))
+	})
 })
@@ -0,0 +1,108 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profile", func() {
+	var dir string
+	var path string
+
+	writeFile := func(name string, content string) {
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "config")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "config.yml")
+		writeFile("config.yml", `
+name: Base
+count: 1
+nested:
+  a: 1
+  b: 2
+profiles:
+  staging:
+    count: 2
+  prod:
+    count: 3
+    nested:
+      b: 3
+`)
+		writeFile("config.prod.yml", "name: Prod\n")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should load the base file without profile", func() {
+		var cfg MyWatchConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path)).To(Succeed())
+		Expect(cfg.Name).To(Equal("Base"))
+		Expect(cfg.Count).To(Equal(1))
+	})
+
+	It("should overlay the section and the sibling file of the profile", func() {
+		var cfg MyWatchConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path, "-profile=prod")).To(Succeed())
+		Expect(cfg.Name).To(Equal("Prod"))
+		Expect(cfg.Count).To(Equal(3))
+
+		data, err := config.NewProfileFileSource(path, "prod").Load()
+		Expect(err).To(BeNil())
+		Expect(data["nested"]).To(Equal(map[string]interface{}{"a": 1, "b": 3}))
+		Expect(data).NotTo(HaveKey(config.ProfilesKey))
+	})
+
+	It("should apply flags over the profile", func() {
+		var cfg MyWatchConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path, "-profile=prod", "-count=4")).To(Succeed())
+		Expect(cfg.Name).To(Equal("Prod"))
+		Expect(cfg.Count).To(Equal(4))
+	})
+
+	It("should select the profile by environment variable", func() {
+		os.Setenv("CONFIG_PROFILE", "staging")
+		defer os.Unsetenv("CONFIG_PROFILE")
+
+		var cfg MyWatchConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path)).To(Succeed())
+		Expect(cfg.Name).To(Equal("Base"))
+		Expect(cfg.Count).To(Equal(2))
+	})
+
+	It("should select the profile by the config file", func() {
+		writeFile("config.yml", "profile: prod\ncount: 1\nprofiles:\n  prod:\n    count: 3\n")
+
+		var cfg MyWatchConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path)).To(Succeed())
+		Expect(cfg.Name).To(Equal("Prod"))
+		Expect(cfg.Count).To(Equal(3))
+	})
+
+	It("should fail on unknown profile", func() {
+		var cfg MyWatchConfig
+		err := config.NewLoader("test").Load(&cfg, "-yaml="+path, "-profile=dev")
+		Expect(err).To(MatchError("profile \"dev\" not found in " + path))
+	})
+
+	It("should show the selected profile in effective options", func() {
+		var cfg MyWatchConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path, "-profile=prod")).To(Succeed())
+
+		var buf bytes.Buffer
+		Expect(config.Dump(&buf, &cfg, config.DumpYAML)).To(Succeed())
+		Expect(buf.String()).To(Equal("profile: prod # flag\n" +
+			"name: Prod # file " + path + " (profile prod)\n" +
+			"count: 3 # file " + path + " (profile prod)\n"))
+	})
+})
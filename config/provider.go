@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProviderTimeout is the timeout of fetching values from a Provider used as a Source.
+	DefaultProviderTimeout = 10 * time.Second
+
+	// DefaultProviderInterval is the interval a Provider polls for changes.
+	DefaultProviderInterval = 30 * time.Second
+)
+
+// Provider is a key/value config service that supplies values of options keyed by option names.
+type Provider interface {
+	// Name describes the provider in messages, e.g. the URL of the service.
+	Name() string
+
+	// Fetch returns current values of options.
+	Fetch(ctx context.Context) (map[string]interface{}, error)
+
+	// Watch calls onChange with new values whenever values change, until the context is done.
+	// Watch blocks and returns the error of the context.
+	Watch(ctx context.Context, onChange func(map[string]interface{})) error
+}
+
+type providerSource struct {
+	provider Provider
+	timeout  time.Duration
+}
+
+// NewProviderSource creates a Source that fetches values from the Provider.
+// Fetching times out after DefaultProviderTimeout.
+func NewProviderSource(provider Provider) Source {
+	return &providerSource{provider: provider, timeout: DefaultProviderTimeout}
+}
+
+func (s *providerSource) Name() string {
+	return s.provider.Name()
+}
+
+func (s *providerSource) Load() (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	return s.provider.Fetch(ctx)
+}
+
+// HTTPProvider fetches values of options from a JSON object served over HTTP.
+// Responses carrying an ETag are revalidated by If-None-Match on later fetches.
+type HTTPProvider struct {
+	// Client is the client to make requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Interval is the interval to poll for changes. Defaults to DefaultProviderInterval.
+	Interval time.Duration
+
+	url string
+
+	mu     sync.Mutex
+	etag   string
+	values map[string]interface{}
+}
+
+// NewHTTPProvider creates a HTTPProvider that fetches values from the URL.
+func NewHTTPProvider(url string) *HTTPProvider {
+	return &HTTPProvider{url: url}
+}
+
+func (p *HTTPProvider) Name() string {
+	return p.url
+}
+
+func (p *HTTPProvider) Fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && p.values != nil:
+		return p.values, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status from %s: %s", p.url, resp.Status)
+	}
+
+	values, err := decodeValues(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid values from %s: %v", p.url, err)
+	}
+	p.etag, p.values = resp.Header.Get("ETag"), values
+	return values, nil
+}
+
+func (p *HTTPProvider) Watch(ctx context.Context, onChange func(map[string]interface{})) error {
+	return pollProvider(ctx, p, p.Interval, onChange)
+}
+
+// FileProvider fetches values of options from a JSON file, which stands in for a config service locally.
+type FileProvider struct {
+	// Interval is the interval to poll for changes. Defaults to DefaultProviderInterval.
+	Interval time.Duration
+
+	path string
+}
+
+// NewFileProvider creates a FileProvider that fetches values from the JSON file.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (p *FileProvider) Name() string {
+	return p.path
+}
+
+func (p *FileProvider) Fetch(ctx context.Context) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := decodeValues(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid values from %s: %v", p.path, err)
+	}
+	return values, nil
+}
+
+func (p *FileProvider) Watch(ctx context.Context, onChange func(map[string]interface{})) error {
+	return pollProvider(ctx, p, p.Interval, onChange)
+}
+
+// decodeValues decodes a JSON object, keeping numbers as json.Number to preserve large integers.
+func decodeValues(r io.Reader) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// pollProvider fetches values every interval and calls onChange if values change.
+// Failed fetches are logged and retried on the next interval.
+func pollProvider(ctx context.Context, p Provider, interval time.Duration, onChange func(map[string]interface{})) error {
+	if interval <= 0 {
+		interval = DefaultProviderInterval
+	}
+	log := GetLogger(LogConfig)
+	last, err := p.Fetch(ctx)
+	if err != nil {
+		log.Warn("Failed to fetch %s: %v", p.Name(), err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		values, err := p.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn("Failed to fetch %s: %v", p.Name(), err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(values, last) {
+			last = values
+			onChange(values)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package config_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// configService is a key/value config service serving a JSON object with an ETag.
+type configService struct {
+	mu       sync.Mutex
+	values   map[string]interface{}
+	version  int
+	status   int
+	requests int
+	revalids int
+}
+
+func (s *configService) set(values map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values = values
+	s.version++
+}
+
+func (s *configService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	etag := "\"" + string(rune('a'+s.version)) + "\""
+	if r.Header.Get("If-None-Match") == etag {
+		s.revalids++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	json.NewEncoder(w).Encode(s.values)
+}
+
+var _ = Describe("Provider", func() {
+	var service *configService
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		service = &configService{values: map[string]interface{}{"name": "Remote", "count": 2, "seed": int64(1234567890123456789)}}
+		server = httptest.NewServer(service)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should fetch values over HTTP and revalidate by ETag", func() {
+		provider := config.NewHTTPProvider(server.URL)
+		values, err := provider.Fetch(context.Background())
+		Expect(err).To(BeNil())
+		Expect(values).To(HaveKeyWithValue("name", "Remote"))
+
+		values, err = provider.Fetch(context.Background())
+		Expect(err).To(BeNil())
+		Expect(values).To(HaveKeyWithValue("name", "Remote"))
+		Expect(service.requests).To(Equal(2))
+		Expect(service.revalids).To(Equal(1))
+	})
+
+	It("should fail on unexpected status", func() {
+		service.status = http.StatusInternalServerError
+		_, err := config.NewHTTPProvider(server.URL).Fetch(context.Background())
+		Expect(err).To(MatchError("unexpected status from " + server.URL + ": 500 Internal Server Error"))
+	})
+
+	It("should layer remote values under flags and the config file", func() {
+		var cfg MyEffectiveConfig
+		flagSet, err := config.ValidateOptionsWithSources(&cfg,
+			[]config.Source{config.NewProviderSource(config.NewHTTPProvider(server.URL))},
+			"-yaml=options_test.yml", "-count=5")
+		checkFlagSet(flagSet, err)
+
+		Expect(err).To(BeNil())
+		Expect(cfg.Name).To(Equal("Tianium"))
+		Expect(cfg.Count).To(Equal(5))
+		Expect(cfg.Seed).To(Equal(int64(1234567890123456789)))
+
+		settings, err := config.Effective(&cfg)
+		Expect(err).To(BeNil())
+		Expect(settings[2].Name).To(Equal("seed"))
+		Expect(settings[2].Origin).To(Equal(config.OriginSource))
+		Expect(settings[2].Source).To(Equal(server.URL))
+	})
+
+	It("should skip null values from sources", func() {
+		service.set(map[string]interface{}{"name": nil, "count": 2})
+		var cfg MyDefaultConfig
+		loader := config.NewLoader("test", config.NewProviderSource(config.NewHTTPProvider(server.URL)))
+		Expect(loader.Load(&cfg)).To(Succeed())
+		Expect(cfg.Name).To(Equal("Tianium"))
+		Expect(cfg.Count).To(Equal(2))
+	})
+
+	It("should reject nested values from sources", func() {
+		for _, value := range []interface{}{map[string]interface{}{"first": "Elle"}, []interface{}{"Elle"}} {
+			service.set(map[string]interface{}{"name": value})
+			var cfg MyDefaultConfig
+			loader := config.NewLoader("test", config.NewProviderSource(config.NewHTTPProvider(server.URL)))
+			err := loader.Load(&cfg)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("\"name\" from " + server.URL))
+		}
+	})
+
+	It("should watch changes over HTTP", func() {
+		provider := config.NewHTTPProvider(server.URL)
+		provider.Interval = 10 * time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		changes := make(chan map[string]interface{}, 10)
+		go func() {
+			done <- provider.Watch(ctx, func(values map[string]interface{}) {
+				changes <- values
+			})
+		}()
+
+		Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+		service.set(map[string]interface{}{"name": "Changed"})
+		Eventually(changes, time.Second).Should(Receive(Equal(map[string]interface{}{"name": "Changed"})))
+
+		cancel()
+		Eventually(done, time.Second).Should(Receive(Equal(context.Canceled)))
+	})
+
+	It("should fetch and watch values from a file", func() {
+		dir, err := os.MkdirTemp("", "config")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "remote.json")
+		Expect(os.WriteFile(path, []byte(`{"name": "File", "count": 3}`), 0644)).To(Succeed())
+
+		provider := config.NewFileProvider(path)
+		provider.Interval = 10 * time.Millisecond
+		var cfg MyWatchConfig
+		Expect(config.NewLoader("test", config.NewProviderSource(provider)).Load(&cfg)).To(Succeed())
+		Expect(cfg.Name).To(Equal("File"))
+		Expect(cfg.Count).To(Equal(3))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		changes := make(chan map[string]interface{}, 10)
+		go func() {
+			done <- provider.Watch(ctx, func(values map[string]interface{}) {
+				changes <- values
+			})
+		}()
+
+		Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+		Expect(os.WriteFile(path, []byte(`{"name": "Changed"}`), 0644)).To(Succeed())
+		Eventually(changes, time.Second).Should(Receive(HaveKeyWithValue("name", "Changed")))
+
+		cancel()
+		Eventually(done, time.Second).Should(Receive(Equal(context.Canceled)))
+	})
+})
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SchemaDraft is the JSON Schema dialect written by WriteSchema.
+	SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+)
+
+var (
+	ErrInvalidConfig = errors.New("invalid config file")
+)
+
+// schema is a subset of JSON Schema covering options.
+type schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Deprecated           bool               `json:"deprecated,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// WriteSchema writes the JSON Schema of config files of the options, so that editors can validate them.
+// Options, including embedded ones like LoggerOptions, are described by their types, descriptions,
+// defaults, values listed by the "oneof" tag and aliases, which are marked deprecated.
+// Secrets have no defaults in the schema.
+//
+// The options are registered with a FlagSet of their own if not registered yet.
+func WriteSchema(w io.Writer, opts Options) error {
+	s, err := schemaOf(opts)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// ValidateFile validates the config file against the schema of the options, see WriteSchema.
+// Unknown options, values of wrong types and values not listed by the "oneof" tag are reported as
+// ErrInvalidConfig. Profiles in the file are validated too.
+//
+// The options are registered with a FlagSet of their own if not registered yet.
+func ValidateFile(opts Options, path string) error {
+	s, err := schemaOf(opts)
+	if err != nil {
+		return err
+	}
+
+	data, err := loadFile(path)
+	if err != nil {
+		return err
+	}
+	return s.validate(path, data)
+}
+
+func schemaOf(opts Options) (*schema, error) {
+	meta, err := metaOf(opts)
+	if err == ErrNotRegistered {
+		fresh := cloneOptions(reflect.ValueOf(opts).Elem()).Addr().Interface().(Options)
+		if err := NewLoader("schema").Register(fresh); err != nil {
+			return nil, err
+		}
+		meta = fresh.meta()
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := &schema{
+		Schema:               SchemaDraft,
+		Title:                groupName(meta.raw.Type()),
+		Type:                 "object",
+		Properties:           make(map[string]*schema),
+		AdditionalProperties: false,
+	}
+	for _, field := range meta.fields {
+		// The config file can not specify itself.
+		if field.name == "yaml" {
+			continue
+		}
+		prop, err := fieldSchema(field)
+		if err != nil {
+			return nil, err
+		}
+		s.Properties[field.name] = prop
+		for _, alias := range field.aliases {
+			deprecated := *prop
+			deprecated.Description = fmt.Sprintf("Deprecated, use %s.", field.name)
+			deprecated.Deprecated = true
+			s.Properties[alias] = &deprecated
+		}
+	}
+	s.Properties[ProfilesKey] = &schema{
+		Description:          "Profiles overlaying the config file.",
+		Type:                 "object",
+		AdditionalProperties: &schema{Ref: "#"},
+	}
+	return s, nil
+}
+
+func fieldSchema(field *optionField) (*schema, error) {
+	s := &schema{Description: field.desc, Deprecated: field.deprecated != "" && !field.aliased()}
+	switch field.kind {
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int64:
+		s.Type = "integer"
+	case reflect.Uint, reflect.Uint64:
+		s.Type = "integer"
+		s.Minimum = new(int)
+	case reflect.Float64:
+		s.Type = "number"
+	default:
+		s.Type = "string"
+	}
+
+	if !field.secret && field.defValue != "" {
+		def, err := schemaValue(s.Type, field.kind, field.defValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value \"%s\" for \"%s\": %v", field.defValue, field.name, err)
+		}
+		s.Default = def
+	}
+	for _, str := range field.oneof {
+		val, err := schemaValue(s.Type, field.kind, str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid oneof value \"%s\" for \"%s\": %v", str, field.name, err)
+		}
+		s.Enum = append(s.Enum, val)
+	}
+	return s, nil
+}
+
+// schemaValue parses str as a JSON value of the type, of which the field is of the kind.
+func schemaValue(typ string, kind reflect.Kind, str string) (interface{}, error) {
+	switch typ {
+	case "boolean":
+		return strconv.ParseBool(str)
+	case "integer":
+		if kind == reflect.Uint || kind == reflect.Uint64 {
+			return strconv.ParseUint(str, 0, 64)
+		}
+		return strconv.ParseInt(str, 0, 64)
+	case "number":
+		return strconv.ParseFloat(str, 64)
+	default:
+		return str, nil
+	}
+}
+
+func (s *schema) validate(path string, data map[string]interface{}) error {
+	var problems []string
+	s.collect("", data, &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w %s: %s", ErrInvalidConfig, path, strings.Join(problems, "; "))
+}
+
+// collect collects problems of values in the object, of which the path is prefix.
+func (s *schema) collect(prefix string, data map[string]interface{}, problems *[]string) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		prop, ok := s.Properties[key]
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("unknown option \"%s%s\"", prefix, key))
+			continue
+		} else if key != ProfilesKey {
+			if problem := prop.check(data[key]); problem != "" {
+				*problems = append(*problems, fmt.Sprintf("\"%s%s\" %s", prefix, key, problem))
+			}
+			continue
+		}
+
+		profiles := toStringMap(data[key])
+		if profiles == nil {
+			*problems = append(*problems, fmt.Sprintf("\"%s%s\" must be an object", prefix, key))
+			continue
+		}
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			profile := toStringMap(profiles[name])
+			if profile == nil {
+				*problems = append(*problems, fmt.Sprintf("\"%s%s.%s\" must be an object", prefix, key, name))
+				continue
+			}
+			s.collect(fmt.Sprintf("%s%s.%s.", prefix, key, name), profile, problems)
+		}
+	}
+}
+
+// check returns the problem of the value, or an empty string if valid.
+func (s *schema) check(v interface{}) string {
+	var valid bool
+	var negative bool
+	switch n := v.(type) {
+	case bool:
+		valid = s.Type == "boolean"
+	case int:
+		valid, negative = s.Type == "integer" || s.Type == "number", n < 0
+	case int64:
+		valid, negative = s.Type == "integer" || s.Type == "number", n < 0
+	case uint64:
+		valid = s.Type == "integer" || s.Type == "number"
+	case float64:
+		valid, negative = s.Type == "number" || s.Type == "integer" && n == float64(int64(n)), n < 0
+	case string:
+		valid = s.Type == "string"
+	}
+	if !valid {
+		return fmt.Sprintf("must be %s", article(s.Type))
+	} else if negative && s.Minimum != nil {
+		return fmt.Sprintf("must be at least %d", *s.Minimum)
+	}
+
+	if len(s.Enum) == 0 {
+		return ""
+	}
+	allowed := make([]string, len(s.Enum))
+	for i, e := range s.Enum {
+		allowed[i] = fmt.Sprintf("%v", e)
+		if allowed[i] == fmt.Sprintf("%v", v) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))
+}
+
+func article(typ string) string {
+	if typ == "integer" {
+		return "an " + typ
+	}
+	return "a " + typ
+}
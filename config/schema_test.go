@@ -0,0 +1,124 @@
+package config_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MySchemaConfig struct {
+	config.LoggerOptions
+
+	Name    string  `name:"name" default:"Tianium" alias:"title" description:"Option \"name\"."`
+	Mode    string  `name:"mode" default:"fast" oneof:"fast,slow" description:"Option \"mode\"."`
+	Workers uint    `name:"workers" default:"4" description:"Option \"workers\"."`
+	Ratio   float64 `name:"ratio" description:"Option \"ratio\"."`
+	Key     string  `name:"key" default:"insecure-key" secret:"true" description:"API key."`
+}
+
+type MyUnsignedSchemaConfig struct {
+	config.Options
+
+	Mask  uint64 `name:"mask" default:"18446744073709551615" description:"Option \"mask\"."`
+	Shard uint   `name:"shard" oneof:"0,9223372036854775808" description:"Option \"shard\"."`
+}
+
+var _ = Describe("Schema", func() {
+	var dir string
+
+	writeFile := func(content string) string {
+		path := filepath.Join(dir, "config.yml")
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "config")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+		config.LogLevel = config.DefaultLogLevel
+	})
+
+	It("should write the schema of unregistered options", func() {
+		var buf bytes.Buffer
+		Expect(config.WriteSchema(&buf, &MySchemaConfig{})).To(Succeed())
+
+		var schema map[string]interface{}
+		Expect(json.Unmarshal(buf.Bytes(), &schema)).To(Succeed())
+		Expect(schema["$schema"]).To(Equal(config.SchemaDraft))
+		Expect(schema["type"]).To(Equal("object"))
+		Expect(schema["additionalProperties"]).To(Equal(false))
+
+		props := schema["properties"].(map[string]interface{})
+		Expect(props).NotTo(HaveKey("yaml"))
+		Expect(props).To(HaveKey("profile"))
+		Expect(props).To(HaveKey("debug"))
+		Expect(props["name"]).To(Equal(map[string]interface{}{
+			"description": "Option \"name\".", "type": "string", "default": "Tianium"}))
+		Expect(props["title"]).To(HaveKeyWithValue("deprecated", true))
+		Expect(props["mode"]).To(HaveKeyWithValue("enum", []interface{}{"fast", "slow"}))
+		Expect(props["workers"]).To(HaveKeyWithValue("type", "integer"))
+		Expect(props["workers"]).To(HaveKeyWithValue("default", float64(4)))
+		Expect(props["workers"]).To(HaveKeyWithValue("minimum", float64(0)))
+		Expect(props["ratio"]).To(HaveKeyWithValue("type", "number"))
+		Expect(props["debug"]).To(HaveKeyWithValue("default", false))
+		Expect(props["key"]).NotTo(HaveKey("default"))
+		Expect(props[config.ProfilesKey]).To(HaveKeyWithValue("additionalProperties", map[string]interface{}{"$ref": "#"}))
+	})
+
+	It("should write unsigned values beyond the range of signed integers", func() {
+		var buf bytes.Buffer
+		Expect(config.WriteSchema(&buf, &MyUnsignedSchemaConfig{})).To(Succeed())
+
+		var schema map[string]interface{}
+		decoder := json.NewDecoder(&buf)
+		decoder.UseNumber()
+		Expect(decoder.Decode(&schema)).To(Succeed())
+		props := schema["properties"].(map[string]interface{})
+		Expect(props["mask"]).To(HaveKeyWithValue("default", json.Number("18446744073709551615")))
+		Expect(props["shard"]).To(HaveKeyWithValue("enum", []interface{}{json.Number("0"), json.Number("9223372036854775808")}))
+
+		path := writeFile("shard: 9223372036854775808\n")
+		Expect(config.ValidateFile(&MyUnsignedSchemaConfig{}, path)).To(Succeed())
+	})
+
+	It("should validate a valid config file", func() {
+		path := writeFile("name: Elle\nmode: slow\nworkers: 2\nratio: 1\ndebug: true\nprofiles:\n  prod:\n    workers: 8\n")
+		Expect(config.ValidateFile(&MySchemaConfig{}, path)).To(Succeed())
+	})
+
+	It("should report problems of an invalid config file", func() {
+		path := writeFile("name: 1\nmode: medium\nworkers: -1\nnames: [a]\nprofiles:\n  prod:\n    debug: yes please\n")
+		err := config.ValidateFile(&MySchemaConfig{}, path)
+		Expect(err).To(MatchError(config.ErrInvalidConfig))
+		Expect(err.Error()).To(Equal("invalid config file " + path + ": " +
+			"\"mode\" must be one of fast, slow; " +
+			"\"name\" must be a string; " +
+			"unknown option \"names\"; " +
+			"\"profiles.prod.debug\" must be a boolean; " +
+			"\"workers\" must be at least 0"))
+	})
+
+	It("should validate the config file before loading in strict mode", func() {
+		path := writeFile("name: Elle\ncount: 1\n")
+
+		var cfg MySchemaConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-yaml="+path)).To(Succeed())
+		Expect(cfg.Name).To(Equal("Elle"))
+
+		loader := config.NewLoader("test")
+		loader.Strict = true
+		err := loader.Load(&cfg, "-yaml="+path)
+		Expect(err).To(MatchError(config.ErrInvalidConfig))
+		Expect(err.Error()).To(HaveSuffix("unknown option \"count\""))
+	})
+})
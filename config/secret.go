@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces values of secret options in usage and dumps.
+const Redacted = "<redacted>"
+
+// SecretResolver resolves a secret from the reference with the scheme trimmed,
+// e.g. "///run/secrets/key" of "file:///run/secrets/key".
+type SecretResolver func(ref string) (string, error)
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"file": resolveFileSecret,
+		"env":  resolveEnvSecret,
+	}
+)
+
+// RegisterSecretResolver registers a resolver for references of secret options in the form of "scheme:ref",
+// e.g. a secret store. Resolvers of the "file" and "env" schemes are registered by default.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+
+	secretResolvers[scheme] = resolver
+}
+
+// resolveSecret replaces the value of a secret option with the secret it refers to.
+// Values without a registered scheme are taken literally.
+func resolveSecret(field *optionField) error {
+	field.ref = ""
+	ref := field.value.String()
+	idx := strings.Index(ref, ":")
+	if idx < 0 {
+		return nil
+	}
+
+	secretResolversMu.RLock()
+	resolver, ok := secretResolvers[ref[:idx]]
+	secretResolversMu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	secret, err := resolver(ref[idx+1:])
+	if err != nil {
+		return fmt.Errorf("failed to resolve secret \"%s\" for \"%s\": %v", ref, field.name, err)
+	}
+	field.value.SetString(secret)
+	field.ref = ref
+	return nil
+}
+
+func resolveFileSecret(ref string) (string, error) {
+	data, err := os.ReadFile(strings.TrimPrefix(ref, "//"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func resolveEnvSecret(ref string) (string, error) {
+	secret, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", ref)
+	}
+	return secret, nil
+}
@@ -0,0 +1,121 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MySecretConfig struct {
+	config.Options
+
+	Key string `name:"key" default:"insecure-key" secret:"true" description:"API key."`
+}
+
+type MyInvalidSecretConfig struct {
+	config.Options
+
+	Pin int `name:"pin" secret:"true" description:"PIN."`
+}
+
+var _ = Describe("Secret", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "config")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should redact secrets in usage", func() {
+		var cfg MySecretConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg, "-h")).To(Equal(config.ErrPrintUsage))
+
+		var buf bytes.Buffer
+		Expect(config.WriteUsage(&buf, &cfg, config.UsageText)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("(default \"<redacted>\", yaml key)"))
+		buf.Reset()
+		Expect(config.WriteUsage(&buf, &cfg, config.UsageMarkdown)).To(Succeed())
+		Expect(buf.String()).NotTo(ContainSubstring("insecure-key"))
+
+		buf.Reset()
+		loader.FlagSet().SetOutput(&buf)
+		loader.FlagSet().PrintDefaults()
+		Expect(buf.String()).NotTo(ContainSubstring("insecure-key"))
+	})
+
+	It("should resolve file references and redact them in dumps", func() {
+		path := filepath.Join(dir, "key")
+		Expect(os.WriteFile(path, []byte("s3cr3t\n"), 0600)).To(Succeed())
+
+		var cfg MySecretConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-key=file://"+path)).To(Succeed())
+		Expect(cfg.Key).To(Equal("s3cr3t"))
+
+		settings, err := config.Effective(&cfg)
+		Expect(err).To(BeNil())
+		key := settings[len(settings)-1]
+		Expect(key.Value).To(Equal(config.Redacted))
+		Expect(key.Ref).To(Equal("file://" + path))
+		Expect(key.String()).NotTo(ContainSubstring("s3cr3t"))
+
+		var buf bytes.Buffer
+		Expect(config.Dump(&buf, &cfg, config.DumpYAML)).To(Succeed())
+		Expect(buf.String()).To(Equal("key: file://" + path + " # flag\n"))
+	})
+
+	It("should resolve environment references", func() {
+		os.Setenv("MY_SECRET_KEY", "s3cr3t")
+		defer os.Unsetenv("MY_SECRET_KEY")
+
+		var cfg MySecretConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-key=env:MY_SECRET_KEY")).To(Succeed())
+		Expect(cfg.Key).To(Equal("s3cr3t"))
+
+		var buf bytes.Buffer
+		Expect(config.Dump(&buf, &cfg, config.DumpJSON)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("\"key\": \"env:MY_SECRET_KEY\""))
+	})
+
+	It("should redact literal secrets in dumps", func() {
+		var cfg MySecretConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-key=s3cr3t")).To(Succeed())
+		Expect(cfg.Key).To(Equal("s3cr3t"))
+
+		var buf bytes.Buffer
+		Expect(config.Dump(&buf, &cfg, config.DumpYAML)).To(Succeed())
+		Expect(buf.String()).To(Equal("key: <redacted> # flag\n"))
+	})
+
+	It("should fail on unresolvable references", func() {
+		var cfg MySecretConfig
+		err := config.NewLoader("test").Load(&cfg, "-key=env:MY_SECRET_MISSING")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("MY_SECRET_MISSING"))
+	})
+
+	It("should resolve references by registered resolvers", func() {
+		config.RegisterSecretResolver("vault", func(ref string) (string, error) {
+			return strings.ToUpper(ref), nil
+		})
+
+		var cfg MySecretConfig
+		Expect(config.NewLoader("test").Load(&cfg, "-key=vault:secret/key")).To(Succeed())
+		Expect(cfg.Key).To(Equal("SECRET/KEY"))
+	})
+
+	It("should reject secrets other than strings", func() {
+		var cfg MyInvalidSecretConfig
+		Expect(config.NewLoader("test").Load(&cfg)).To(HaveOccurred())
+	})
+})
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"encoding/binary"
+	"hash/fnv"
 	"math/rand"
 	"time"
 )
@@ -11,10 +13,33 @@ type SeedOptions struct {
 	Seed int64 `name:"seed" description:"Random seed to reproduce simulation."`
 }
 
+// Validate generates a seed if none is specified and logs the seed to reproduce the run.
+// The global random source is left untouched, use Rand to get a random source of a component.
 func (opts *SeedOptions) Validate() error {
 	if opts.Seed == 0 {
 		opts.Seed = time.Now().UnixNano()
 	}
-	rand.Seed(opts.Seed)
+	GetLogger(LogConfig).Info("Random seed: %d", opts.Seed)
 	return nil
 }
+
+// Rand returns a new random source of the component, which is independent of other components
+// and reproducible with the same seed. The returned source is not safe for concurrent use.
+func (opts *SeedOptions) Rand(component string) *rand.Rand {
+	return rand.New(rand.NewSource(opts.SubSeed(component)))
+}
+
+// SubSeed derives the seed of the component from the seed.
+func (opts *SeedOptions) SubSeed(component string) int64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(opts.Seed))
+	h := fnv.New64a()
+	h.Write(buf[:])
+	h.Write([]byte(component))
+
+	// Finalize by splitmix64 to spread seeds of similar names.
+	z := h.Sum64() + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
@@ -0,0 +1,48 @@
+package config_test
+
+import (
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SeedOptions", func() {
+	sequence := func(opts *config.SeedOptions, component string) []int64 {
+		rnd := opts.Rand(component)
+		seq := make([]int64, 5)
+		for i := range seq {
+			seq[i] = rnd.Int63()
+		}
+		return seq
+	}
+
+	It("should generate a seed if none specified", func() {
+		var cfg MyCompositeExtension
+		Expect(config.NewLoader("test").Load(&cfg)).To(Succeed())
+		Expect(cfg.Seed).NotTo(Equal(int64(0)))
+	})
+
+	It("should derive reproducible random sources", func() {
+		var a, b MyCompositeExtension
+		Expect(config.NewLoader("test").Load(&a, "-seed=123")).To(Succeed())
+		Expect(config.NewLoader("test").Load(&b, "-seed=123")).To(Succeed())
+		Expect(sequence(&a.SeedOptions, "network")).To(Equal(sequence(&b.SeedOptions, "network")))
+		Expect(a.SubSeed("network")).To(Equal(b.SubSeed("network")))
+	})
+
+	It("should derive independent random sources of components", func() {
+		opts := &config.SeedOptions{Seed: 123}
+		Expect(sequence(opts, "network")).NotTo(Equal(sequence(opts, "disk")))
+		Expect(opts.SubSeed("network")).NotTo(Equal(opts.SubSeed("network2")))
+		Expect(opts.SubSeed("network")).NotTo(Equal((&config.SeedOptions{Seed: 124}).SubSeed("network")))
+	})
+
+	It("should not be affected by other components", func() {
+		opts := &config.SeedOptions{Seed: 123}
+		expected := sequence(opts, "network")
+
+		disk := opts.Rand("disk")
+		disk.Int63()
+		Expect(sequence(opts, "network")).To(Equal(expected))
+	})
+})
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	configKit "github.com/gookit/config/v2"
+	"github.com/gookit/config/v2/yaml"
+	"github.com/mitchellh/mapstructure"
+)
+
+// ProfilesKey is the key of the section of profile overlays in config files.
+const ProfilesKey = "profiles"
+
+// Source supplies values of options keyed by option names.
+type Source interface {
+	// Name describes the source in messages, e.g. the path of a file.
+	Name() string
+
+	// Load returns values of options keyed by option names.
+	Load() (map[string]interface{}, error)
+}
+
+type fileSource struct {
+	path    string
+	profile string
+}
+
+// NewFileSource creates a Source that loads options from a config file in the yml format.
+func NewFileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+// NewProfileFileSource creates a Source that loads options from a config file in the yml format,
+// overlaid by the profile. Overlays of a profile, e.g. "prod", are merged deeply in order:
+//
+//  1. The base file, e.g. "config.yml", in which the "profiles" section is excluded.
+//  2. The section of the profile in the base file, e.g. "profiles.prod".
+//  3. The sibling file of the profile, e.g. "config.prod.yml".
+//
+// If profile is empty, the "profile" key of the base file selects the profile if any.
+// A profile specified but not found in either place is an error.
+func NewProfileFileSource(path string, profile string) Source {
+	return &fileSource{path: path, profile: profile}
+}
+
+func (s *fileSource) Name() string {
+	if s.profile == "" {
+		return s.path
+	}
+	return fmt.Sprintf("%s (profile %s)", s.path, s.profile)
+}
+
+func (s *fileSource) Load() (map[string]interface{}, error) {
+	data, err := loadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := toStringMap(data[ProfilesKey])
+	delete(data, ProfilesKey)
+	profile := s.profile
+	if profile == "" {
+		if selected, ok := data["profile"].(string); ok {
+			profile = selected
+		} else {
+			return data, nil
+		}
+	}
+
+	found := false
+	if overlay, ok := profiles[profile]; ok {
+		data = mergeDeep(data, toStringMap(overlay))
+		found = true
+	}
+	if sibling := profilePath(s.path, profile); fileExists(sibling) {
+		overlay, err := loadFile(sibling)
+		if err != nil {
+			return nil, err
+		}
+		data = mergeDeep(data, overlay)
+		found = true
+	}
+	if !found && s.profile != "" {
+		return nil, fmt.Errorf("profile \"%s\" not found in %s", profile, s.path)
+	}
+	return data, nil
+}
+
+func loadFile(path string) (map[string]interface{}, error) {
+	config := configKit.NewWithOptions("", func(opt *configKit.Options) {
+		opt.TagName = OptionName
+		// DecoderConfig initialization is due a bug in configKit: no TagName will be applied if DecoderConfig is nil.
+		// TODO: Fix the bug
+		opt.DecoderConfig = &mapstructure.DecoderConfig{}
+	})
+	config.AddDriver(yaml.Driver)
+
+	if err := config.LoadFiles(path); err != nil {
+		return nil, err
+	}
+	return config.Data(), nil
+}
+
+// profilePath returns the path of the sibling file of the profile, e.g. "config.prod.yml" of "config.yml".
+func profilePath(path string, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// mergeDeep merges overlay into base recursively, values of overlay take precedence.
+func mergeDeep(base map[string]interface{}, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		if baseMap := toStringMap(merged[k]); baseMap != nil {
+			if overlayMap := toStringMap(v); overlayMap != nil {
+				merged[k] = mergeDeep(baseMap, overlayMap)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// toStringMap converts maps decoded from yml to map[string]interface{}. Returns nil if v is not a map.
+func toStringMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			converted[fmt.Sprintf("%v", k)] = v
+		}
+		return converted
+	default:
+		return nil
+	}
+}
+
+type mapSource struct {
+	name   string
+	values map[string]interface{}
+}
+
+// NewMapSource creates a Source that supplies specified values.
+func NewMapSource(name string, values map[string]interface{}) Source {
+	return &mapSource{name: name, values: values}
+}
+
+func (s *mapSource) Name() string {
+	return s.name
+}
+
+func (s *mapSource) Load() (map[string]interface{}, error) {
+	return s.values, nil
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// UsageFormat is the output format of the usage.
+type UsageFormat int
+
+const (
+	// UsageText renders the usage as plain text like flag.PrintDefaults.
+	UsageText UsageFormat = iota
+	// UsageMarkdown renders the usage as Markdown tables, one per options struct.
+	UsageMarkdown
+)
+
+// PrintUsage prints the usage of the options to the output of the FlagSet they are registered to.
+// The options must have been registered by a Loader, ValidateOptions or ValidateOptionsWithFlags.
+func PrintUsage(opts Options) {
+	meta, err := metaOf(opts)
+	if err != nil {
+		Flag.PrintDefaults()
+		return
+	}
+	_ = WriteUsage(meta.flags.Output(), opts, UsageText)
+}
+
+// WriteUsage writes the usage of the options in specified format.
+// Options are grouped by the struct declared them, along with the type, default value,
+// environment variable and YAML key of each option.
+// The options must have been registered by a Loader, ValidateOptions or ValidateOptionsWithFlags.
+func WriteUsage(w io.Writer, opts Options, format UsageFormat) error {
+	meta, err := metaOf(opts)
+	if err != nil {
+		return err
+	}
+
+	groups, fields := groupFields(meta.fields)
+	switch format {
+	case UsageText:
+		return writeTextUsage(w, meta.flags.Name(), groups, fields)
+	case UsageMarkdown:
+		return writeMarkdownUsage(w, groups, fields)
+	default:
+		return fmt.Errorf("unsupported usage format: %d", format)
+	}
+}
+
+func metaOf(opts Options) (meta *options, err error) {
+	defer func() {
+		// Options embedded in opts can be nil if never validated.
+		if recover() != nil {
+			meta, err = nil, ErrNotRegistered
+		}
+	}()
+
+	meta = opts.meta()
+	if meta.flags == nil {
+		return nil, ErrNotRegistered
+	}
+	return meta, nil
+}
+
+func groupFields(all []*optionField) ([]string, map[string][]*optionField) {
+	var groups []string
+	fields := make(map[string][]*optionField)
+	for _, field := range all {
+		if _, ok := fields[field.group]; !ok {
+			groups = append(groups, field.group)
+		}
+		fields[field.group] = append(fields[field.group], field)
+	}
+	return groups, fields
+}
+
+func writeTextUsage(w io.Writer, name string, groups []string, fields map[string][]*optionField) error {
+	var b strings.Builder
+	if name == "" {
+		b.WriteString("Usage:\n")
+	} else {
+		fmt.Fprintf(&b, "Usage of %s:\n", name)
+	}
+	b.WriteString("  -h\tShow help.\n")
+	writeTextGroups(&b, groups, fields)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeTextGroups(b *strings.Builder, groups []string, fields map[string][]*optionField) {
+	for _, group := range groups {
+		fmt.Fprintf(b, "\n%s:\n", group)
+		for _, field := range fields[group] {
+			fmt.Fprintf(b, "  -%s %s\n    \t%s", field.name, field.kind, field.desc)
+
+			notes := []string{fmt.Sprintf("default %s", quoteDefault(field))}
+			if len(field.oneof) > 0 {
+				notes = append(notes, "one of "+strings.Join(field.oneof, "|"))
+			}
+			if field.env != "" {
+				notes = append(notes, "env $"+field.env)
+			}
+			notes = append(notes, "yaml "+field.name)
+			fmt.Fprintf(b, " (%s)\n", strings.Join(notes, ", "))
+		}
+	}
+}
+
+func writeMarkdownUsage(w io.Writer, groups []string, fields map[string][]*optionField) error {
+	var b strings.Builder
+	for i, group := range groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "### %s\n\n", group)
+		b.WriteString("| Flag | Type | Default | Env | YAML | Description |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+		for _, field := range fields[group] {
+			env := ""
+			if field.env != "" {
+				env = "`" + field.env + "`"
+			}
+			fmt.Fprintf(&b, "| `-%s` | %s | `%s` | %s | `%s` | %s |\n",
+				field.name, field.kind, redactDefault(field), env, field.name, strings.ReplaceAll(field.desc, "|", "\\|"))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func quoteDefault(field *optionField) string {
+	if field.kind == reflect.String {
+		return fmt.Sprintf("%q", redactDefault(field))
+	}
+	return field.defValue
+}
+
+func redactDefault(field *optionField) string {
+	if field.secret && field.defValue != "" {
+		return Redacted
+	}
+	return field.defValue
+}
@@ -0,0 +1,55 @@
+package config_test
+
+import (
+	"bytes"
+
+	"github.com/Scusemua/go-utils/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Usage", func() {
+	AfterEach(func() {
+		config.LogLevel = config.DefaultLogLevel
+	})
+
+	It("should group options by the struct declared them", func() {
+		var cfg MyCompositeConfig
+		_, err := config.ValidateOptionsWithFlags(&cfg, "-h")
+		Expect(err).To(Equal(config.ErrPrintUsage))
+
+		var buf bytes.Buffer
+		Expect(config.WriteUsage(&buf, &cfg, config.UsageText)).To(Succeed())
+		usage := buf.String()
+		Expect(usage).To(ContainSubstring("\nOptions:\n  -yaml string\n"))
+		Expect(usage).To(ContainSubstring("\nSeedOptions:\n  -seed int64\n"))
+		Expect(usage).To(ContainSubstring("\nLoggerOptions:\n  -debug bool\n"))
+		Expect(usage).To(ContainSubstring("(default 0, yaml seed)"))
+	})
+
+	It("should show defaults and environment variables", func() {
+		var cfg MyDefaultConfig
+		_, err := config.ValidateOptionsWithFlags(&cfg)
+		Expect(err).To(BeNil())
+
+		var buf bytes.Buffer
+		Expect(config.WriteUsage(&buf, &cfg, config.UsageText)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("\nMyDefaultConfig:\n  -name string\n    \tOption \"name\". (default \"Tianium\", env $MY_DEFAULT_NAME, yaml name)\n"))
+	})
+
+	It("should render markdown tables", func() {
+		var cfg MyDefaultConfig
+		_, err := config.ValidateOptionsWithFlags(&cfg)
+		Expect(err).To(BeNil())
+
+		var buf bytes.Buffer
+		Expect(config.WriteUsage(&buf, &cfg, config.UsageMarkdown)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("### MyDefaultConfig\n\n| Flag | Type | Default | Env | YAML | Description |\n"))
+		Expect(buf.String()).To(ContainSubstring("| `-count` | int | `3` | `MY_DEFAULT_COUNT` | `count` | Option \"count\". |\n"))
+	})
+
+	It("should fail on options never registered", func() {
+		var cfg MyConfig
+		Expect(config.WriteUsage(&bytes.Buffer{}, &cfg, config.UsageText)).To(Equal(config.ErrNotRegistered))
+	})
+})
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/Scusemua/go-utils/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	ErrNoConfigFile = errors.New("no config file to watch")
+)
+
+// Watcher reloads options when the config file changes.
+//
+// Options are reloaded into a fresh copy of the options struct, in which values set by flags
+// keep their precedence, and values computed by validation, e.g. a generated seed, are kept.
+// Subscribers are notified only if the reloaded options pass validation and differ from the
+// current ones. Validators run on every reload, and their side effects persist even if a later
+// validator fails, except for the logger settings of LoggerOptions, which are restored.
+type Watcher struct {
+	loader *Loader
+	path   string
+	fsw    *fsnotify.Watcher
+	log    logger.Logger
+
+	reloadMu    sync.Mutex
+	mu          sync.Mutex
+	current     Options
+	subscribers []*subscriber
+	nextID      int
+}
+
+type subscriber struct {
+	id int
+	fn func(old, new Options)
+}
+
+// Watch watches the config file specified by "-yaml" of the options resolved by the Loader,
+// as well as the sibling file of the selected profile.
+// Close the Watcher to stop watching.
+func (l *Loader) Watch() (*Watcher, error) {
+	if l.opts == nil {
+		return nil, ErrNotRegistered
+	}
+	if l.opts.meta().YAML == "" {
+		return nil, ErrNoConfigFile
+	}
+
+	path, err := filepath.Abs(l.opts.meta().YAML)
+	if err != nil {
+		return nil, err
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Watch the directory to follow the file being replaced, which is how most editors save files.
+	if err := fsw.Add(filepath.Dir(path)); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	w := &Watcher{
+		loader:  l,
+		path:    path,
+		fsw:     fsw,
+		current: l.opts,
+	}
+	InitLogger(&w.log, LogConfig)
+	go w.watch()
+	return w, nil
+}
+
+// Options returns the options last reloaded successfully.
+func (w *Watcher) Options() Options {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.current
+}
+
+// Subscribe registers a function to be called with old and new options after a successful reload.
+// Returns a function that cancels the subscription.
+func (w *Watcher) Subscribe(fn func(old, new Options)) (unsubscribe func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.nextID++
+	id := w.nextID
+	w.subscribers = append(w.subscribers, &subscriber{id: id, fn: fn})
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		for i, sub := range w.subscribers {
+			if sub.id == id {
+				w.subscribers = append(w.subscribers[:i], w.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Reload reloads the options immediately and notifies subscribers on changes.
+func (w *Watcher) Reload() error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	fresh, err := w.loader.reload()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	old := w.current
+	if equalOptions(old, fresh) {
+		w.mu.Unlock()
+		return nil
+	}
+	w.current = fresh
+	subscribers := make([]*subscriber, len(w.subscribers))
+	copy(subscribers, w.subscribers)
+	w.mu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.fn(old, fresh)
+	}
+	return nil
+}
+
+// Close stops watching the config file.
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+func (w *Watcher) watch() {
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if !w.watches(event.Name) || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if err := w.Reload(); err != nil {
+				w.log.Warn("Failed to reload %s: %v", w.path, err)
+			}
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.log.Warn("Error on watching %s: %v", w.path, err)
+		}
+	}
+}
+
+// watches returns true if the file is the config file or the sibling file of the current profile.
+func (w *Watcher) watches(name string) bool {
+	name = filepath.Clean(name)
+	if name == w.path {
+		return true
+	}
+	profile := w.Options().meta().Profile
+	return profile != "" && name == profilePath(w.path, profile)
+}
+
+// reload loads a fresh copy of the options with the flags last resolved. Values computed by the
+// last validation are kept as defaults, so that e.g. a generated seed does not change on reload.
+func (l *Loader) reload() (Options, error) {
+	fresh := cloneOptions(l.base).Addr().Interface().(Options)
+	loader := NewLoader(l.flags.Name())
+	loader.copySettings(l)
+	if err := loader.Register(fresh); err != nil {
+		return nil, err
+	}
+	for name, val := range l.flagged {
+		if name == "h" || loader.flags.Lookup(name) == nil {
+			continue
+		}
+		if err := loader.flags.Set(name, val); err != nil {
+			return nil, fmt.Errorf("invalid value \"%s\" for \"%s\": %v", val, name, err)
+		}
+	}
+	for name, val := range l.computed {
+		// Set the value of the flag directly to keep the origin of the default.
+		if f := loader.flags.Lookup(name); f != nil {
+			if err := f.Value.Set(val); err != nil {
+				return nil, fmt.Errorf("invalid value \"%s\" for \"%s\": %v", val, name, err)
+			}
+		}
+	}
+
+	// Validators of the fresh copy may set globals, e.g. LoggerOptions sets LogLevel and Verbose.
+	// Restore those of the package if the reload fails.
+	logMu.RLock()
+	level, verbose := LogLevel, Verbose
+	logMu.RUnlock()
+	if err := loader.Resolve(); err != nil {
+		logMu.Lock()
+		LogLevel, Verbose = level, verbose
+		logMu.Unlock()
+		return nil, err
+	}
+	return fresh, nil
+}
+
+func equalOptions(a, b Options) bool {
+	fa, fb := a.meta().fields, b.meta().fields
+	if len(fa) != len(fb) {
+		return false
+	}
+	for i := range fa {
+		if !reflect.DeepEqual(fa[i].value.Interface(), fb[i].value.Interface()) {
+			return false
+		}
+	}
+	return true
+}
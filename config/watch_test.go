@@ -0,0 +1,205 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Scusemua/go-utils/config"
+	"github.com/Scusemua/go-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MyWatchConfig struct {
+	config.Options
+
+	Name  string `name:"name" description:"Option \"name\"."`
+	Count int    `name:"count" description:"Option \"count\"."`
+}
+
+func (c *MyWatchConfig) Validate() error {
+	if c.Count < 0 {
+		return errors.New("negative count")
+	}
+	return nil
+}
+
+type MyWatchExtension struct {
+	Name  string `name:"name" description:"Option \"name\"."`
+	Count int    `name:"count" description:"Option \"count\"."`
+}
+
+func (c *MyWatchExtension) Validate() error {
+	if c.Count < 0 {
+		return errors.New("negative count")
+	}
+	return nil
+}
+
+type MyWatchSeedConfig struct {
+	config.SeedOptions
+	Logger config.LoggerOptions
+	Watch  MyWatchExtension
+}
+
+var _ = Describe("Watcher", func() {
+	var dir string
+	var path string
+
+	writeConfig := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "config")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "config.yml")
+		writeConfig("name: Tianium\ncount: 1\n")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+		// The watcher may be reloading yet, set logger settings synchronized.
+		(&config.LoggerOptions{}).Validate()
+	})
+
+	It("should notify subscribers on file changes", func() {
+		var cfg MyWatchConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg, "-yaml="+path)).To(Succeed())
+		Expect(cfg.Count).To(Equal(1))
+
+		watcher, err := loader.Watch()
+		Expect(err).To(BeNil())
+		defer watcher.Close()
+
+		changes := make(chan [2]*MyWatchConfig, 10)
+		watcher.Subscribe(func(old, new config.Options) {
+			changes <- [2]*MyWatchConfig{old.(*MyWatchConfig), new.(*MyWatchConfig)}
+		})
+
+		writeConfig("name: Tianium\ncount: 2\n")
+		var change [2]*MyWatchConfig
+		Eventually(changes, time.Second).Should(Receive(&change))
+		Expect(change[0]).To(BeIdenticalTo(&cfg))
+		Expect(change[0].Count).To(Equal(1))
+		Expect(change[1].Count).To(Equal(2))
+		Expect(watcher.Options()).To(BeIdenticalTo(change[1]))
+	})
+
+	It("should keep the precedence of flags", func() {
+		var cfg MyWatchConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg, "-yaml="+path, "-name=Elle")).To(Succeed())
+
+		watcher, err := loader.Watch()
+		Expect(err).To(BeNil())
+		defer watcher.Close()
+
+		writeConfig("name: Tianium\ncount: 3\n")
+		Expect(watcher.Reload()).To(Succeed())
+		reloaded := watcher.Options().(*MyWatchConfig)
+		Expect(reloaded.Name).To(Equal("Elle"))
+		Expect(reloaded.Count).To(Equal(3))
+		Expect(cfg.Count).To(Equal(1))
+	})
+
+	It("should not notify if reloaded options are invalid or unchanged", func() {
+		var cfg MyWatchConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg, "-yaml="+path)).To(Succeed())
+
+		watcher, err := loader.Watch()
+		Expect(err).To(BeNil())
+		defer watcher.Close()
+
+		notified := 0
+		unsubscribe := watcher.Subscribe(func(old, new config.Options) {
+			notified++
+		})
+		Expect(watcher.Reload()).To(Succeed())
+		Expect(notified).To(Equal(0))
+
+		writeConfig("name: Tianium\ncount: -1\n")
+		Expect(watcher.Reload()).To(MatchError("negative count"))
+		Expect(watcher.Options()).To(BeIdenticalTo(&cfg))
+
+		unsubscribe()
+		writeConfig("name: Tianium\ncount: 5\n")
+		Expect(watcher.Reload()).To(Succeed())
+		Expect(notified).To(Equal(0))
+		Expect(watcher.Options().(*MyWatchConfig).Count).To(Equal(5))
+	})
+
+	It("should validate reloaded config files in strict mode", func() {
+		var cfg MyWatchConfig
+		loader := config.NewLoader("test")
+		loader.Strict = true
+		Expect(loader.Load(&cfg, "-yaml="+path)).To(Succeed())
+
+		watcher, err := loader.Watch()
+		Expect(err).To(BeNil())
+		defer watcher.Close()
+
+		writeConfig("name: Tianium\ncount: 2\nbogus: 1\n")
+		err = watcher.Reload()
+		Expect(err).To(MatchError(config.ErrInvalidConfig))
+		Expect(err.Error()).To(ContainSubstring("unknown option \"bogus\""))
+		Expect(watcher.Options()).To(BeIdenticalTo(&cfg))
+	})
+
+	It("should keep values computed by validation", func() {
+		var cfg MyWatchSeedConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg, "-yaml="+path)).To(Succeed())
+		Expect(cfg.Seed).NotTo(Equal(int64(0)))
+
+		watcher, err := loader.Watch()
+		Expect(err).To(BeNil())
+		defer watcher.Close()
+
+		notified := 0
+		watcher.Subscribe(func(old, new config.Options) {
+			notified++
+		})
+		Expect(watcher.Reload()).To(Succeed())
+		Expect(notified).To(Equal(0))
+
+		writeConfig("name: Tianium\ncount: 2\n")
+		Expect(watcher.Reload()).To(Succeed())
+		Expect(notified).To(Equal(1))
+		Expect(watcher.Options().(*MyWatchSeedConfig).Seed).To(Equal(cfg.Seed))
+	})
+
+	It("should restore logger settings if reloaded options are invalid", func() {
+		var cfg MyWatchSeedConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg, "-yaml="+path)).To(Succeed())
+
+		watcher, err := loader.Watch()
+		Expect(err).To(BeNil())
+		defer watcher.Close()
+
+		writeConfig("debug: true\nv: true\ncount: -1\n")
+		Expect(watcher.Reload()).To(MatchError("negative count"))
+		log := config.GetDefaultLogger().(*logger.ColorLogger)
+		Expect(log.Level).To(Equal(config.DefaultLogLevel))
+		Expect(log.Verbose).To(BeFalse())
+
+		writeConfig("debug: true\ncount: 1\n")
+		Expect(watcher.Reload()).To(Succeed())
+		Expect(config.GetDefaultLogger().(*logger.ColorLogger).Level).To(Equal(logger.LOG_LEVEL_ALL))
+	})
+
+	It("should fail to watch without config file", func() {
+		var cfg MyWatchConfig
+		loader := config.NewLoader("test")
+		Expect(loader.Load(&cfg)).To(Succeed())
+
+		_, err := loader.Watch()
+		Expect(err).To(Equal(config.ErrNoConfigFile))
+	})
+})
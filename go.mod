@@ -3,6 +3,7 @@ module github.com/Scusemua/go-utils
 go 1.18
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/gookit/config/v2 v2.1.2
 	github.com/jordwest/mock-conn v0.0.0-20180617021051-4896c6bd1641
 	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d
@@ -10,11 +11,11 @@ require (
 	github.com/onsi/ginkgo v1.15.0
 	github.com/onsi/gomega v1.10.5
 	github.com/zhangjyr/hashmap v1.0.2
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/dchest/siphash v1.1.0 // indirect
-	github.com/fsnotify/fsnotify v1.4.9 // indirect
 	github.com/gookit/goutil v0.5.2 // indirect
 	github.com/imdario/mergo v0.3.13 // indirect
 	github.com/mattn/go-colorable v0.1.8 // indirect
@@ -25,5 +26,4 @@ require (
 	golang.org/x/sys v0.0.0-20220412211240-33da011f77ad // indirect
 	golang.org/x/text v0.3.5 // indirect
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )